@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+)
+
+// chat actions telegram accepts, the action is displayed for 5 seconds or until bot sends a message
+var chatActions = map[string]bool{
+	"typing":            true,
+	"upload_photo":      true,
+	"record_video":      true,
+	"upload_video":      true,
+	"record_voice":      true,
+	"upload_voice":      true,
+	"upload_document":   true,
+	"choose_sticker":    true,
+	"find_location":     true,
+	"record_video_note": true,
+	"upload_video_note": true,
+}
+
+// uploadActionInterval is less than 5 seconds action is displayed for, so that it does not disappear during upload
+var uploadActionInterval = 4 * time.Second
+
+// uploadAction returns chat action displayed while file of the type is uploaded
+func uploadAction(fileType FileType) string {
+	switch fileType {
+	case PHOTO:
+		return "upload_photo"
+	case VIDEO:
+		return "upload_video"
+	default:
+		return "upload_document"
+	}
+}
+
+func (a *application) chatAction(chatID string, action string) bool {
+	if err := a.tgClient.SendAction(chatID, action); err != nil {
+		log.Error("Error sending chat action ", err)
+		return false
+	}
+	return true
+}
+
+// uploadActionOption reads upload_action option of send and sendAlbum: true shows upload action for attachment of any size,
+// false never shows it
+func uploadActionOption(options interface{}) (enabled bool, ok bool) {
+	spec, _ := options.(map[string]interface{})
+	enabled, ok = spec["upload_action"].(bool)
+	return
+}
+
+// wantsUploadAction reports whether upload action is shown for upload of the size, upload_action option overrides UPLOAD_ACTION_SIZE
+func (a *application) wantsUploadAction(size int64, options interface{}) bool {
+	if enabled, ok := uploadActionOption(options); ok {
+		return enabled
+	}
+	return a.uploadActionSize > 0 && size >= a.uploadActionSize
+}
+
+// fileSize returns size of a local file, zero if it can not be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// showUploadProgress displays upload action while upload of the size is in progress if it is wanted,
+// the action is repeated until returned stop function is called
+func (a *application) showUploadProgress(chatID string, action string, size int64, options interface{}) (stop func()) {
+	if !a.wantsUploadAction(size, options) {
+		return func() {}
+	}
+
+	a.chatAction(chatID, action)
+
+	ticker := time.NewTicker(uploadActionInterval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				a.chatAction(chatID, action)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (a *application) getChatActionFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		action := "typing"
+		if call.Argument(1).IsString() {
+			action = call.Argument(1).String()
+		}
+		if !chatActions[action] {
+			log.Error("Error sending chat action, unsupported action ", action)
+			return otto.FalseValue()
+		}
+
+		result, _ := otto.ToValue(a.chatAction(chatIDArgument(call.Argument(0), userID), action))
+
+		return result
+	}
+}
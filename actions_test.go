@@ -0,0 +1,93 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestChatAction(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot}
+
+	vm := otto.New()
+	vm.Set("chatAction", a.getChatActionFunc(userID))
+
+	telebot.On("SendAction", userID, "typing").Return(nil)
+	telebot.On("SendAction", "456", "upload_document").Return(nil)
+
+	val, err := vm.Run(`[chatAction(), chatAction("456", "upload_document"), chatAction(null, "dancing")].join(",")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "true,true,false", val.String())
+	telebot.AssertExpectations(t)
+}
+
+func TestSendMessageUploadAction(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, attachmentsDir: attachmentsDir, uploadActionSize: 1 << 20}
+
+	telebot.On("SendAction", userID, "upload_video").Return(nil).Once()
+	telebot.On("AttachVideo", userID, filepath.Join(attachmentsDir, "puppy.mp4"), text, mock.AnythingOfType("func(url.Values)")).Return(1, nil)
+	telebot.On("AttachPhoto", userID, filepath.Join(attachmentsDir, "smile.jpg"), text, mock.AnythingOfType("func(url.Values)")).Return(2, nil)
+
+	a.sendMessage(userID, text, nil, nil, "puppy.mp4")
+	a.sendMessage(userID, text, nil, nil, "smile.jpg")
+
+	telebot.AssertExpectations(t)
+	telebot.AssertNumberOfCalls(t, "SendAction", 1)
+
+	//upload_action option overrides UPLOAD_ACTION_SIZE
+	telebot.On("SendAction", userID, "upload_photo").Return(nil).Once()
+
+	a.sendMessage(userID, text, map[string]interface{}{"upload_action": false}, nil, "puppy.mp4")
+	a.sendMessage(userID, text, map[string]interface{}{"upload_action": true}, nil, "smile.jpg")
+
+	telebot.AssertExpectations(t)
+	telebot.AssertNumberOfCalls(t, "SendAction", 2)
+}
+
+func TestSendUploadActionOption(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, attachmentsDir: attachmentsDir}
+
+	vm := otto.New()
+	vm.Set("send", a.getSendFunc(userID))
+
+	telebot.On("SendAction", userID, "upload_photo").Return(nil).Once()
+	telebot.On("AttachPhoto", userID, filepath.Join(attachmentsDir, "smile.jpg"), text, mock.AnythingOfType("func(url.Values)")).Return(1, nil)
+
+	_, err := vm.Run(`send("` + text + `", {upload_action: true}, "smile.jpg")`)
+
+	assert.Nil(t, err)
+	telebot.AssertExpectations(t)
+}
+
+func TestUploadActionRepeated(t *testing.T) {
+	defaultInterval := uploadActionInterval
+	uploadActionInterval = 10 * time.Millisecond
+	defer func() { uploadActionInterval = defaultInterval }()
+
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, attachmentsDir: attachmentsDir, uploadActionSize: 1}
+
+	telebot.On("SendAction", userID, "upload_document").Return(nil)
+	telebot.On("AttachFile", userID, filepath.Join(attachmentsDir, "document.txt"), text, mock.AnythingOfType("func(url.Values)")).Return(1, nil).
+		Run(func(args mock.Arguments) { time.Sleep(55 * time.Millisecond) })
+
+	a.sendMessage(userID, text, nil, nil, "document.txt")
+
+	//action is not repeated after upload
+	calls := len(telebot.Calls)
+	time.Sleep(30 * time.Millisecond)
+
+	telebot.AssertExpectations(t)
+	assert.True(t, calls >= 4, calls)
+	assert.Equal(t, calls, len(telebot.Calls))
+	assert.Equal(t, "upload_document", uploadAction(AUDIO))
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+	"github.com/yanzay/tbot/v2"
+)
+
+// administrator rights granted by promoteMember, rights which are not set are revoked
+var promotionRights = []string{"can_manage_chat", "can_change_info", "can_post_messages", "can_edit_messages", "can_delete_messages",
+	"can_invite_users", "can_restrict_members", "can_pin_messages", "can_promote_members", "can_manage_video_chats", "is_anonymous"}
+
+func promotionSettings(rights map[string]interface{}) func(url.Values) {
+	return func(r url.Values) {
+		for _, key := range promotionRights {
+			r.Set(key, strconv.FormatBool(boolField(rights, key)))
+		}
+	}
+}
+
+// chatPermissions converts permissions object with telegram names (can_send_messages etc.) to chat permissions, missing ones are denied
+func chatPermissions(permissions map[string]interface{}) *tbot.ChatPermissions {
+	result := &tbot.ChatPermissions{}
+
+	data, err := json.Marshal(permissions)
+	if err == nil {
+		err = json.Unmarshal(data, result)
+	}
+	if err != nil {
+		log.Error("Error reading chat permissions ", err)
+	}
+
+	return result
+}
+
+// untilArgument converts optional Date or delay (milliseconds or duration string) to unix time, zero means forever
+func untilArgument(val otto.Value) (int64, error) {
+	if !val.IsDefined() || val.IsNull() {
+		return 0, nil
+	}
+	if val.Class() == "Date" {
+		until, err := parseTime(val)
+		return until.Unix(), err
+	}
+
+	delay, err := parseDelay(val)
+	if err != nil {
+		return 0, err
+	}
+	return time.Now().Add(delay).Unix(), nil
+}
+
+// objectArgument exports optional object argument, returns empty map if it is missing
+func objectArgument(call otto.FunctionCall, index int) map[string]interface{} {
+	objInterface, err := exportJSON(call.Otto, call.Argument(index))
+	if err != nil {
+		log.Error("Error reading argument ", err)
+	}
+	if obj, ok := objInterface.(map[string]interface{}); ok {
+		return obj
+	}
+	return map[string]interface{}{}
+}
+
+// memberArguments reads chat id (current chat if null) and user id arguments of member functions
+func memberArguments(call otto.FunctionCall, userID string) (string, int, bool) {
+	memberID, err := call.Argument(1).ToInteger()
+	if err != nil || memberID == 0 {
+		log.Error("Error managing chat member, invalid user id ", call.Argument(1))
+		return "", 0, false
+	}
+
+	return chatIDArgument(call.Argument(0), userID), int(memberID), true
+}
+
+// adminResult logs failed admin operation and converts its outcome to js boolean
+func adminResult(operation string, err error) otto.Value {
+	if err != nil {
+		log.Error("Error "+operation+" ", err)
+	}
+
+	result, _ := otto.ToValue(err == nil)
+	return result
+}
+
+func (a *application) getBanMemberFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		chatID, memberID, ok := memberArguments(call, userID)
+		if !ok {
+			return otto.FalseValue()
+		}
+		until, err := untilArgument(call.Argument(2))
+		if err != nil {
+			return adminResult("banning chat member", err)
+		}
+
+		return adminResult("banning chat member", a.tgClient.BanMember(chatID, memberID, until))
+	}
+}
+
+func (a *application) getUnbanMemberFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		chatID, memberID, ok := memberArguments(call, userID)
+		if !ok {
+			return otto.FalseValue()
+		}
+
+		return adminResult("unbanning chat member", a.tgClient.UnbanMember(chatID, memberID))
+	}
+}
+
+func (a *application) getRestrictMemberFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		chatID, memberID, ok := memberArguments(call, userID)
+		if !ok {
+			return otto.FalseValue()
+		}
+		until, err := untilArgument(call.Argument(3))
+		if err != nil {
+			return adminResult("restricting chat member", err)
+		}
+
+		permissions := chatPermissions(objectArgument(call, 2))
+
+		return adminResult("restricting chat member", a.tgClient.RestrictMember(chatID, memberID, permissions, until))
+	}
+}
+
+func (a *application) getPromoteMemberFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		chatID, memberID, ok := memberArguments(call, userID)
+		if !ok {
+			return otto.FalseValue()
+		}
+
+		return adminResult("promoting chat member", a.tgClient.PromoteMember(chatID, memberID, promotionSettings(objectArgument(call, 2))))
+	}
+}
+
+func (a *application) getPinMessageFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		msgID, err := call.Argument(1).ToInteger()
+		if err != nil {
+			return adminResult("pinning message", err)
+		}
+		silent, _ := call.Argument(2).ToBoolean()
+
+		return adminResult("pinning message", a.tgClient.PinMsg(chatIDArgument(call.Argument(0), userID), int(msgID), silent))
+	}
+}
+
+func (a *application) getUnpinMessageFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		var msgID int64
+		if call.Argument(1).IsNumber() {
+			msgID, _ = call.Argument(1).ToInteger()
+		}
+
+		return adminResult("unpinning message", a.tgClient.UnpinMsg(chatIDArgument(call.Argument(0), userID), int(msgID)))
+	}
+}
+
+func (a *application) getSetChatTitleFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		title, _ := call.Argument(1).ToString()
+
+		return adminResult("setting chat title", a.tgClient.SetTitle(chatIDArgument(call.Argument(0), userID), title))
+	}
+}
+
+func (a *application) getSetChatDescriptionFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		var description string
+		if call.Argument(1).IsString() {
+			description = call.Argument(1).String()
+		}
+
+		return adminResult("setting chat description", a.tgClient.SetDescription(chatIDArgument(call.Argument(0), userID), description))
+	}
+}
+
+// getSetChatPhotoFunc returns function setting chat photo from attachments directory
+func (a *application) getSetChatPhotoFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		photo, _ := call.Argument(1).ToString()
+
+		photoFile := filepath.Join(a.attachmentsDir, photo)
+		if !FileExists(photoFile) {
+			log.Error("Error setting chat photo, file not found ", photoFile)
+			return otto.FalseValue()
+		}
+
+		return adminResult("setting chat photo", a.tgClient.SetPhoto(chatIDArgument(call.Argument(0), userID), photoFile))
+	}
+}
+
+func (a *application) getGetChatAdminsFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		admins, err := a.tgClient.GetAdmins(chatIDArgument(call.Argument(0), userID))
+		if err != nil {
+			log.Error("Error getting chat administrators ", err)
+			return otto.Value{}
+		}
+
+		result, _ := call.Otto.ToValue(admins)
+
+		return result
+	}
+}
+
+func (a *application) getGetChatMemberCountFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		count, err := a.tgClient.CountMembers(chatIDArgument(call.Argument(0), userID))
+		if err != nil {
+			log.Error("Error getting chat member count ", err)
+			return otto.Value{}
+		}
+
+		result, _ := otto.ToValue(count)
+
+		return result
+	}
+}
+
+// getCreateInviteLinkFunc returns function creating additional invite link with optional name, expire (Date or delay), member_limit and join_request settings
+func (a *application) getCreateInviteLinkFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		settings := objectArgument(call, 1)
+
+		var expireDate int64
+		if call.Argument(1).IsObject() {
+			expire, err := call.Argument(1).Object().Get("expire")
+			if err == nil {
+				expireDate, err = untilArgument(expire)
+			}
+			if err != nil {
+				log.Error("Error creating invite link, invalid expire ", err)
+				return otto.Value{}
+			}
+		}
+
+		link, err := a.tgClient.CreateInviteLink(chatIDArgument(call.Argument(0), userID), func(r url.Values) {
+			if name, ok := stringField(settings, "name"); ok {
+				r.Set("name", name)
+			}
+			if expireDate > 0 {
+				r.Set("expire_date", strconv.FormatInt(expireDate, 10))
+			}
+			if memberLimit, ok := stringField(settings, "member_limit"); ok {
+				r.Set("member_limit", memberLimit)
+			}
+			if boolField(settings, "join_request") {
+				r.Set("creates_join_request", "true")
+			}
+		})
+		if err != nil {
+			log.Error("Error creating invite link ", err)
+			return otto.Value{}
+		}
+
+		result, _ := otto.ToValue(link)
+
+		return result
+	}
+}
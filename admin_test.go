@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yanzay/tbot/v2"
+)
+
+const groupID = "-100123"
+
+func TestGroupAdministration(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, attachmentsDir: attachmentsDir}
+
+	vm := otto.New()
+	vm.Set("banMember", a.getBanMemberFunc(groupID))
+	vm.Set("unbanMember", a.getUnbanMemberFunc(groupID))
+	vm.Set("restrictMember", a.getRestrictMemberFunc(groupID))
+	vm.Set("promoteMember", a.getPromoteMemberFunc(groupID))
+	vm.Set("pinMessage", a.getPinMessageFunc(groupID))
+	vm.Set("unpinMessage", a.getUnpinMessageFunc(groupID))
+	vm.Set("setChatTitle", a.getSetChatTitleFunc(groupID))
+	vm.Set("setChatDescription", a.getSetChatDescriptionFunc(groupID))
+	vm.Set("setChatPhoto", a.getSetChatPhotoFunc(groupID))
+	vm.Set("getChatAdmins", a.getGetChatAdminsFunc(groupID))
+	vm.Set("getChatMemberCount", a.getGetChatMemberCountFunc(groupID))
+	vm.Set("createInviteLink", a.getCreateInviteLinkFunc(groupID))
+
+	var promotion url.Values
+	var invite url.Values
+	telebot.On("BanMember", groupID, 42, int64(0)).Return(nil)
+	telebot.On("BanMember", "-100456", 43, mock.AnythingOfType("int64")).Return(nil).Run(func(args mock.Arguments) {
+		assert.InDelta(t, time.Now().Add(time.Hour).Unix(), args.Get(2).(int64), 5)
+	})
+	telebot.On("UnbanMember", groupID, 42).Return(nil)
+	telebot.On("RestrictMember", groupID, 42, &tbot.ChatPermissions{CanSendMessages: true}, int64(1600000000)).Return(nil)
+	telebot.On("PromoteMember", groupID, 42, mock.AnythingOfType("func(url.Values)")).Return(nil).Run(func(args mock.Arguments) {
+		promotion = url.Values{}
+		args.Get(2).(func(url.Values))(promotion)
+	})
+	telebot.On("PinMsg", groupID, 7, true).Return(nil)
+	telebot.On("UnpinMsg", groupID, 0).Return(nil)
+	telebot.On("SetTitle", groupID, "Bot lovers").Return(nil)
+	telebot.On("SetDescription", groupID, "").Return(nil)
+	telebot.On("SetPhoto", groupID, filepath.Join(attachmentsDir, "smile.jpg")).Return(nil)
+	telebot.On("GetAdmins", groupID).Return([]*tbot.ChatMember{{User: tbot.User{ID: 1, FirstName: "Admin"}, Status: "creator"}}, nil)
+	telebot.On("CountMembers", groupID).Return(15, nil)
+	telebot.On("CreateInviteLink", groupID, mock.AnythingOfType("func(url.Values)")).Return("https://t.me/+abc", nil).Run(func(args mock.Arguments) {
+		invite = url.Values{}
+		args.Get(1).(func(url.Values))(invite)
+	})
+
+	val, err := vm.Run(`[
+		banMember(null, 42),
+		banMember("-100456", 43, "1h"),
+		unbanMember(null, 42),
+		restrictMember(null, 42, {can_send_messages: true}, new Date(1600000000000)),
+		promoteMember(null, 42, {can_pin_messages: true, can_invite_users: true}),
+		pinMessage(null, 7, true),
+		unpinMessage(),
+		setChatTitle(null, "Bot lovers"),
+		setChatDescription(),
+		setChatPhoto(null, "smile.jpg"),
+		setChatPhoto(null, "missing.jpg"),
+		banMember(null, "not a user"),
+		getChatAdmins()[0].User.FirstName,
+		getChatMemberCount(),
+		createInviteLink(null, {name: "Promo", member_limit: 10, join_request: true})
+	].join(",")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "true,true,true,true,true,true,true,true,true,true,false,false,Admin,15,https://t.me/+abc", val.String())
+	telebot.AssertExpectations(t)
+
+	assert.Equal(t, "true", promotion.Get("can_pin_messages"))
+	assert.Equal(t, "true", promotion.Get("can_invite_users"))
+	assert.Equal(t, "false", promotion.Get("can_promote_members"))
+	assert.Equal(t, url.Values{"name": {"Promo"}, "member_limit": {"10"}, "creates_join_request": {"true"}}, invite)
+}
+
+func TestTbotWrapperPromoteMember(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bottoken/promoteChatMember", r.URL.Path)
+		r.ParseForm()
+		assert.Equal(t, groupID, r.PostForm.Get("chat_id"))
+		assert.Equal(t, "42", r.PostForm.Get("user_id"))
+		assert.Equal(t, "true", r.PostForm.Get("can_promote_members"))
+
+		rw.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer server.Close()
+
+	defaultURL := apiBaseURL
+	apiBaseURL = server.URL
+	defer func() { apiBaseURL = defaultURL }()
+
+	wrapper := &TbotWrapper{token: "token"}
+	err := wrapper.PromoteMember(groupID, 42, promotionSettings(map[string]interface{}{"can_promote_members": true}))
+
+	assert.Nil(t, err)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+)
+
+// limits of telegram media group
+const (
+	minAlbumSize = 2
+	maxAlbumSize = 10
+)
+
+// captionParseMode is parse mode of captions sent by send
+const captionParseMode = "HTML"
+
+type inputMedia struct {
+	Type      string `json:"type"`
+	Media     string `json:"media"`
+	Caption   string `json:"caption,omitempty"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+// albumItem is a file from attachments directory or a forwarded file id, optionally followed by colon and file type.
+// Caption is sent with the same parse mode as send, unless parse mode is set (empty for plain text)
+type albumItem struct {
+	File      string
+	FileType  string
+	Caption   string
+	ParseMode *string
+}
+
+func mediaType(fileType FileType) string {
+	switch fileType {
+	case PHOTO:
+		return "photo"
+	case VIDEO:
+		return "video"
+	case AUDIO:
+		return "audio"
+	default:
+		return "document"
+	}
+}
+
+// buildAlbum converts items to input media, local files are uploaded as attach://fileN
+func (a *application) buildAlbum(items []albumItem) ([]inputMedia, map[string]string) {
+	media := make([]inputMedia, len(items))
+	files := map[string]string{}
+
+	for i, item := range items {
+		m := inputMedia{Caption: item.Caption}
+		if item.Caption != "" {
+			m.ParseMode = captionParseMode
+			if item.ParseMode != nil {
+				m.ParseMode = *item.ParseMode
+			}
+		}
+
+		attachmentFile := filepath.Join(a.attachmentsDir, item.File)
+		if FileExists(attachmentFile) {
+			//file uploading
+			name := fmt.Sprintf("file%d", i)
+			files[name] = attachmentFile
+			m.Media = "attach://" + name
+			m.Type = mediaType(GetFileType(attachmentFile))
+		} else if fileParts := strings.Split(item.File, ":"); len(fileParts) == 2 {
+			//file forwarding, file type is specified
+			m.Media = fileParts[0]
+			m.Type = mediaType(ParseFileType(fileParts[1]))
+		} else {
+			m.Media = item.File
+			m.Type = mediaType(OTHER)
+		}
+		if item.FileType != "" {
+			m.Type = mediaType(ParseFileType(item.FileType))
+		}
+
+		media[i] = m
+	}
+
+	return media, files
+}
+
+// albumUploadAction returns chat action displayed while album is uploaded
+func albumUploadAction(media []inputMedia) string {
+	photos := 0
+	for _, m := range media {
+		switch m.Type {
+		case "video":
+			return uploadAction(VIDEO)
+		case "photo":
+			photos++
+		}
+	}
+	if photos == len(media) {
+		return uploadAction(PHOTO)
+	}
+
+	return uploadAction(OTHER)
+}
+
+// sendAlbum sends items as media group, upload action is shown while local files are uploaded like in send
+func (a *application) sendAlbum(chatID string, items []albumItem, options interface{}) []int {
+	if len(items) < minAlbumSize || len(items) > maxAlbumSize {
+		log.Errorf("Error sending album, it must have from %d to %d items but has %d", minAlbumSize, maxAlbumSize, len(items))
+		return nil
+	}
+
+	media, files := a.buildAlbum(items)
+
+	data, err := json.Marshal(media)
+	if err != nil {
+		log.Error("Error sending album ", err)
+		return nil
+	}
+
+	var size int64
+	for _, file := range files {
+		size += fileSize(file)
+	}
+	stopUploadProgress := func() {}
+	if len(files) > 0 {
+		stopUploadProgress = a.showUploadProgress(chatID, albumUploadAction(media), size, options)
+	}
+
+	ids, err := a.tgClient.SendAlbum(chatID, string(data), files)
+	stopUploadProgress()
+	if err != nil {
+		log.Error("Error sending album ", err)
+	}
+
+	return ids
+}
+
+// albumItems reads items given either as file names or as objects like {file: "smile.jpg", type: "photo", caption: "Smile", parse_mode: "MarkdownV2"}
+func albumItems(val interface{}) []albumItem {
+	list, _ := toSlice(val)
+
+	items := make([]albumItem, 0, len(list))
+	for _, el := range list {
+		switch item := el.(type) {
+		case string:
+			items = append(items, albumItem{File: strings.TrimSpace(item)})
+		case map[string]interface{}:
+			file, _ := stringField(item, "file")
+			fileType, _ := stringField(item, "type")
+			caption, _ := stringField(item, "caption")
+			entry := albumItem{File: strings.TrimSpace(file), FileType: fileType, Caption: caption}
+			if parseMode, ok := stringField(item, "parse_mode"); ok {
+				entry.ParseMode = &parseMode
+			}
+			items = append(items, entry)
+		}
+	}
+
+	return items
+}
+
+func (a *application) getSendAlbumFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		chatID := chatIDArgument(call.Argument(0), userID)
+
+		itemsInterface, err := exportJSON(call.Otto, call.Argument(1))
+		if err != nil {
+			log.Error("Error sending album ", err)
+			return otto.Value{}
+		}
+
+		options, _ := exportJSON(call.Otto, call.Argument(2))
+
+		return toJsValue(call.Otto, a.sendAlbum(chatID, albumItems(itemsInterface), options))
+	}
+}
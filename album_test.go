@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendAlbum(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, attachmentsDir: attachmentsDir}
+
+	media, _ := json.Marshal([]inputMedia{
+		{Type: "photo", Media: "attach://file0", Caption: "Smile", ParseMode: "HTML"},
+		{Type: "video", Media: "attach://file1"},
+		{Type: "photo", Media: "photoID"},
+		{Type: "video", Media: "videoID", Caption: "*Forwarded*", ParseMode: "MarkdownV2"},
+		{Type: "photo", Media: "photoID", Caption: "<plain>"},
+	})
+	files := map[string]string{
+		"file0": filepath.Join(attachmentsDir, "smile.jpg"),
+		"file1": filepath.Join(attachmentsDir, "puppy.mp4"),
+	}
+	telebot.On("SendAlbum", userID, string(media), files).Return([]int{1, 2, 3, 4, 5}, nil)
+
+	vm := otto.New()
+	vm.Set("sendAlbum", a.getSendAlbumFunc(userID))
+
+	val, err := vm.Run(`sendAlbum(null, [{file: "smile.jpg", caption: "Smile"}, "puppy.mp4", "photoID:photo",
+		{file: "videoID", type: "video", caption: "*Forwarded*", parse_mode: "MarkdownV2"}, {file: "photoID:photo", caption: "<plain>", parse_mode: ""}]).join(",")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1,2,3,4,5", val.String())
+	telebot.AssertExpectations(t)
+
+	//upload action is shown on request
+	telebot.On("SendAction", userID, "upload_video").Return(nil).Once()
+
+	val, err = vm.Run(`sendAlbum(null, [{file: "smile.jpg", caption: "Smile"}, "puppy.mp4", "photoID:photo",
+		{file: "videoID", type: "video", caption: "*Forwarded*", parse_mode: "MarkdownV2"}, {file: "photoID:photo", caption: "<plain>", parse_mode: ""}],
+		{upload_action: true}).join(",")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1,2,3,4,5", val.String())
+	telebot.AssertExpectations(t)
+
+	//album must have from 2 to 10 items
+	assert.Nil(t, a.sendAlbum(userID, []albumItem{{File: "smile.jpg"}}, nil))
+	telebot.AssertNumberOfCalls(t, "SendAlbum", 2)
+}
+
+func TestAlbumUploadAction(t *testing.T) {
+	assert.Equal(t, "upload_photo", albumUploadAction([]inputMedia{{Type: "photo"}, {Type: "photo"}}))
+	assert.Equal(t, "upload_video", albumUploadAction([]inputMedia{{Type: "photo"}, {Type: "video"}}))
+	assert.Equal(t, "upload_document", albumUploadAction([]inputMedia{{Type: "audio"}, {Type: "audio"}}))
+}
+
+func TestTbotWrapperSendAlbum(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bottoken/sendMediaGroup", r.URL.Path)
+		assert.Nil(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, userID, r.FormValue("chat_id"))
+		assert.Equal(t, `[{"type":"photo","media":"attach://file0"}]`, r.FormValue("media"))
+
+		_, header, err := r.FormFile("file0")
+		assert.Nil(t, err)
+		assert.Equal(t, "smile.jpg", header.Filename)
+
+		rw.Write([]byte(`{"ok":true,"result":[{"message_id":7}]}`))
+	}))
+	defer server.Close()
+
+	defaultURL := apiBaseURL
+	apiBaseURL = server.URL
+	defer func() { apiBaseURL = defaultURL }()
+
+	wrapper := &TbotWrapper{token: "token"}
+	ids, err := wrapper.SendAlbum(userID, `[{"type":"photo","media":"attach://file0"}]`, map[string]string{"file0": filepath.Join(attachmentsDir, "smile.jpg")})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []int{7}, ids)
+}
@@ -7,15 +7,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
-	"sort"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/ReneKroon/ttlcache"
 	"github.com/elliotchance/orderedmap"
 	"github.com/labstack/gommon/log"
 	"github.com/robertkrimen/otto"
@@ -24,16 +22,23 @@ import (
 )
 
 func (a *application) setCacheItem(key string, val interface{}) {
-	a.cache.Set(key, val)
+	if err := a.cache.Set(key, val); err != nil {
+		log.Error("Error storing cache item ", err)
+	}
 }
 
 func (a *application) getCacheItem(key string) interface{} {
-	val, _ := a.cache.Get(key)
+	val, err := a.cache.Get(key)
+	if err != nil {
+		log.Error("Error reading cache item ", err)
+	}
 	return val
 }
 
 func (a *application) delCacheItem(key string) {
-	a.cache.Remove(key)
+	if err := a.cache.Remove(key); err != nil {
+		log.Error("Error removing cache item ", err)
+	}
 }
 
 func (a *application) getFileLink(fileID string) string {
@@ -42,21 +47,57 @@ func (a *application) getFileLink(fileID string) string {
 		log.Error("Error getting file link ", err)
 		return ""
 	}
-	return fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", a.token, file.FilePath)
+	return fileURL(a.token, file.FilePath)
 }
 
 func (a *application) messageHandler(m *tbot.Message) {
+	if m.SuccessfulPayment != nil && a.handlePayment(m) {
+		return
+	}
+
 	a.handleMessage(m)
 }
 
 func (a *application) callbackHandler(cq *tbot.CallbackQuery) {
 	a.tgClient.AnswerCallback(cq.ID)
 
+	if strings.HasPrefix(cq.Data, captchaPrefix) {
+		a.handleCaptchaAnswer(cq)
+		return
+	}
+
 	a.handleCallback(cq)
 }
 
-func (a *application) replaceInlineOptions(chatID string, msgID int, inlineOptions []map[string]interface{}) int {
-	id, err := a.tgClient.EditInlineMarkup(chatID, msgID, buildInlineOptions(inlineOptions))
+// handleUpdate routes updates received via webhook or long polling to handlers
+func (a *application) handleUpdate(u *Update) {
+	switch {
+	case u.Message != nil:
+		a.messageHandler(u.Message)
+	case u.CallbackQuery != nil:
+		a.callbackHandler(u.CallbackQuery)
+	case u.Poll != nil:
+		a.handlePoll(u.Poll)
+	case u.PollAnswer != nil:
+		a.handlePollAnswer(u.PollAnswer)
+	case u.InlineQuery != nil:
+		a.handleInlineQuery(u.InlineQuery)
+	case u.ChosenInlineResult != nil:
+		a.handleChosenInlineResult(u.ChosenInlineResult)
+	case u.PreCheckoutQuery != nil:
+		a.handlePreCheckout(u.PreCheckoutQuery)
+	case u.ChatMember != nil:
+		a.verifyMember(u.ChatMember)
+		a.handleChatMember("onChatMember", u.ChatMember)
+	case u.MyChatMember != nil:
+		a.handleChatMember("onMyChatMember", u.MyChatMember)
+	case u.ChatJoinRequest != nil:
+		a.handleJoinRequest(u.ChatJoinRequest)
+	}
+}
+
+func (a *application) replaceInlineOptions(chatID string, msgID int, inlineOptions interface{}) int {
+	id, err := a.tgClient.EditInlineMarkup(chatID, msgID, optReplyMarkup(buildInlineOptions(inlineOptions)))
 	if err != nil {
 		log.Error("Error replacing inline options ", err)
 	}
@@ -70,8 +111,8 @@ func (a *application) deleteMessage(chatID string, msgID int) {
 	}
 }
 
-func (a *application) editMessage(chatID string, msgID int, text string, inlineOptions []map[string]interface{}) {
-	err := a.tgClient.EditMsg(chatID, msgID, text, buildInlineOptions(inlineOptions))
+func (a *application) editMessage(chatID string, msgID int, text string, inlineOptions interface{}) {
+	err := a.tgClient.EditMsg(chatID, msgID, text, optReplyMarkup(buildInlineOptions(inlineOptions)))
 	if err != nil {
 		log.Error("Error editing message ", err)
 	}
@@ -112,22 +153,15 @@ func (a *application) ReportDB(userID string, text string, query string, name st
 			report[id+1] = make([]string, 0, row.Len())
 			//append values
 			for _, key := range report[0] {
-				report[id+1] = append(report[id+1], fmt.Sprintf("%v", row.GetOrDefault(key, "")))
+				report[id+1] = append(report[id+1], csvValue(row.GetOrDefault(key, nil)))
 			}
 		}
-		file, err := ioutil.TempFile(a.attachmentsDir, fmt.Sprintf("%s*.csv", name))
-		if err != nil {
-			log.Error("Error creating report on disk ", err)
+		content := &bytes.Buffer{}
+		w := csv.NewWriter(content)
+		if err := w.WriteAll(report); err != nil {
+			log.Error("Error writing report ", err)
 		} else {
-			defer os.Remove(file.Name())
-			defer file.Close()
-			w := csv.NewWriter(file)
-			if err := w.WriteAll(report); err != nil {
-				log.Error("Error saving report to disk ", err)
-			} else {
-				_, basename := filepath.Split(file.Name())
-				return a.sendMessage(userID, text, [][]string{}, []map[string]interface{}{}, basename)
-			}
+			return a.sendContent(userID, text, nil, nil, &fileContent{Name: name + ".csv", Content: content.Bytes(), Type: OTHER})
 		}
 
 	}
@@ -137,88 +171,64 @@ func (a *application) ReportDB(userID string, text string, query string, name st
 }
 
 func (a *application) QueryDB(query string, args []interface{}) []*orderedmap.OrderedMap {
-	result := []*orderedmap.OrderedMap{}
-	if a.dbClient != nil {
-		rows, err := a.dbClient.Query(query, args...)
-		if err != nil {
-			log.Error("Error querying db ", err)
-			return result
-		}
-		defer rows.Close()
-
-		columnTypes, err := rows.ColumnTypes()
-
-		if err != nil {
-			log.Error("Error querying db ", err)
-			return result
-		}
-
-		count := len(columnTypes)
-
-		for rows.Next() {
-
-			scanArgs := make([]interface{}, count)
-
-			for i, v := range columnTypes {
+	if a.dbClient == nil {
+		return []*orderedmap.OrderedMap{}
+	}
 
-				switch v.DatabaseTypeName() {
-				case "VARCHAR", "TEXT", "UUID", "TIMESTAMP":
-					scanArgs[i] = new(sql.NullString)
-					break
-				case "BOOL":
-					scanArgs[i] = new(sql.NullBool)
-					break
-				case "INT4":
-					scanArgs[i] = new(sql.NullInt64)
-					break
-				default:
-					scanArgs[i] = new(sql.NullString)
-				}
-			}
+	result, err := queryDB(a.dbClient, query, args, a.dbLocation)
+	if err != nil {
+		log.Error("Error querying db ", err)
+	}
+	return result
+}
 
-			err := rows.Scan(scanArgs...)
+// dbConn is db connection pool or transaction queries are run on
+type dbConn interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
 
-			if err != nil {
-				log.Error("Error querying db ", err)
-				return result
-			}
+// queryDB returns rows read before an error occurred along with the error, text timestamps are read in time zone loc
+func queryDB(conn dbConn, query string, args []interface{}, loc *time.Location) ([]*orderedmap.OrderedMap, error) {
+	result := []*orderedmap.OrderedMap{}
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
 
-			masterData := orderedmap.NewOrderedMap()
+	columnTypes, err := rows.ColumnTypes()
 
-			for i, v := range columnTypes {
+	if err != nil {
+		return result, err
+	}
 
-				if z, ok := (scanArgs[i]).(*sql.NullBool); ok {
-					masterData.Set(v.Name(), z.Bool)
-					continue
-				}
+	count := len(columnTypes)
 
-				if z, ok := (scanArgs[i]).(*sql.NullString); ok {
-					masterData.Set(v.Name(), z.String)
-					continue
-				}
+	for rows.Next() {
 
-				if z, ok := (scanArgs[i]).(*sql.NullInt64); ok {
-					masterData.Set(v.Name(), z.Int64)
-					continue
-				}
+		values := make([]interface{}, count)
+		scanArgs := make([]interface{}, count)
+		for i := range values {
+			scanArgs[i] = &values[i]
+		}
 
-				if z, ok := (scanArgs[i]).(*sql.NullFloat64); ok {
-					masterData.Set(v.Name(), z.Float64)
-					continue
-				}
+		err := rows.Scan(scanArgs...)
 
-				if z, ok := (scanArgs[i]).(*sql.NullInt32); ok {
-					masterData.Set(v.Name(), z.Int32)
-					continue
-				}
+		if err != nil {
+			return result, err
+		}
 
-				masterData.Set(v.Name(), scanArgs[i])
-			}
+		masterData := orderedmap.NewOrderedMap()
 
-			result = append(result, masterData)
+		for i, v := range columnTypes {
+			masterData.Set(v.Name(), columnValue(v.DatabaseTypeName(), values[i], loc))
 		}
+
+		result = append(result, masterData)
 	}
-	return result
+
+	return result, rows.Err()
 }
 
 func (a *application) ExecDB(query string, args []interface{}) sql.Result {
@@ -237,55 +247,135 @@ func (a *application) ExecDB(query string, args []interface{}) sql.Result {
 func (a *application) initialize() error {
 
 	//prepare js runtime
-	if GetEnv("SCRIPTS", "") == "" {
-		return errors.New("No scripts are configured")
-	}
-
-	scripts := strings.Split(GetEnv("SCRIPTS", ""), ",")
+	a.scheduler = newScheduler(a.runScheduled)
 
-	var b bytes.Buffer
-	for _, scriptPath := range scripts {
-		script, err := ReadFile(scriptPath)
-		if err != nil {
-			return err
-		}
-		b.WriteString(script)
-		b.WriteString("\n")
+	build, err := a.loadScripts()
+	if err != nil {
+		return err
 	}
+	a.vmTemplate, a.scriptFiles = build.vm, build.files
+	a.scheduler.replace(sourceScripts, build.schedules)
 
-	a.vmTemplate = a.createVmTemplate()
-	if _, err := a.vmTemplate.Run(b.String()); err != nil {
+	//configure schedules, TIMER is kept as a schedule of bot.onTimer
+	schedules, err := parseSchedules(GetEnv("SCHEDULES", ""))
+	if err != nil {
 		return err
 	}
-	if _, err := a.vmTemplate.Object("bot"); err != nil {
-		return err
+	if GetEnv("TIMER", "") != "" {
+		schedules = append(schedules, scheduleSpec{spec: "@every " + GetEnv("TIMER", ""), function: "bot.onTimer"})
 	}
+	a.scheduler.replace(sourceConfig, schedules)
 
 	//setup DB connection
 	if GetEnv("DB_DRIVER", "") != "" && GetEnv("DB_CONN_STR", "") != "" {
-		var err error
 		if a.dbClient, err = sql.Open(GetEnv("DB_DRIVER", ""), GetEnv("DB_CONN_STR", "")); err != nil {
 			return err
 		}
 		if err = a.dbClient.Ping(); err != nil {
 			return err
 		}
+		a.dbLocation = dbLocation(GetEnv("DB_DRIVER", ""), GetEnv("DB_CONN_STR", ""))
 	}
 
 	//configure cache
-	a.cache = ttlcache.NewCache()
 	duration, err := time.ParseDuration(GetEnv("CACHE_TTL", "30m"))
 	if err != nil {
 		log.Error("Error parsing time duration for cache ttl, ttl set to 30 minutes ", err)
 		duration = time.Minute * 30
 	}
-	a.cache.SetTTL(duration)
+	if a.cache, err = newSessionStore(GetEnv("SESSION_STORE", "memory"), duration, a.dbClient); err != nil {
+		return err
+	}
+
+	//configure queue of scheduled messages
+	if a.queue, err = newMessageQueue(GetEnv("QUEUE_STORE", "file"), a.dbClient); err != nil {
+		return err
+	}
+
+	//configure size of attachments upload progress is shown for, 0 disables it
+	if size := GetEnv("UPLOAD_ACTION_SIZE", "1MB"); size != "0" {
+		if a.uploadActionSize, err = ParseSize(size); err != nil {
+			log.Error("Error parsing upload action size, upload progress is disabled ", err)
+		}
+	}
+
+	//configure size limit of files downloaded into attachments directory
+	if a.downloadMaxSize, err = ParseSize(GetEnv("DOWNLOAD_MAX_SIZE", defaultDownloadMaxSize)); err != nil {
+		log.Error("Error parsing download max size, default "+defaultDownloadMaxSize+" is used ", err)
+		a.downloadMaxSize, _ = ParseSize(defaultDownloadMaxSize)
+	}
+
+	//configure script execution limit
+	if GetEnv("SCRIPT_TIMEOUT", "") != "" {
+		if a.scriptTimeout, err = time.ParseDuration(GetEnv("SCRIPT_TIMEOUT", "")); err != nil {
+			log.Error("Error parsing time duration for script timeout, timeout is disabled ", err)
+		}
+	}
 
 	return nil
 }
 
+type scriptsBuild struct {
+	vm        Vm
+	files     []string
+	schedules []scheduleSpec
+}
+
+// loadScripts builds a new template vm from configured scripts along with list of loaded files and declared schedules
+func (a *application) loadScripts() (*scriptsBuild, error) {
+	if GetEnv("SCRIPTS", "") == "" {
+		return nil, errors.New("No scripts are configured")
+	}
+
+	scripts := strings.Split(GetEnv("SCRIPTS", ""), ",")
+
+	build := &scriptsBuild{vm: a.createVmTemplate()}
+	vm := build.vm
+
+	//schedules declared while loading are applied only if scripts load successfully
+	vm.Set("schedule", a.getScheduleFunc(&build.schedules))
+
+	for _, scriptPath := range scripts {
+		src, err := ReadFile(scriptPath)
+		if err != nil {
+			return nil, err
+		}
+		//compile each file separately to get file name and line in errors
+		script, err := vm.Compile(scriptPath, src)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := vm.Run(script); err != nil {
+			return nil, scriptError(err)
+		}
+	}
+	if _, err := vm.Object("bot"); err != nil {
+		return nil, err
+	}
+
+	vm.Set("schedule", a.getScheduleFunc(nil))
+	build.files = append(scripts, requiredModules(vm)...)
+
+	return build, nil
+}
+
+// template returns current template vm, it can be swapped by scripts reload
+func (a *application) template() Vm {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.vmTemplate
+}
+
 func (a *application) GetBot(id string) *otto.Object {
-	vm := a.vmTemplate.Copy()
+	bot, _ := a.getVm(id).Object("bot")
+
+	return bot
+}
+
+// getVm returns a copy of template vm with embedded functions bound to the chat
+func (a *application) getVm(id string) Vm {
+	vm := a.template().Copy()
 
 	if id != "" {
 		vm.Set("send", a.getSendFunc(id))
@@ -299,11 +389,142 @@ func (a *application) GetBot(id string) *otto.Object {
 		vm.Set("del", a.getDelFunc(id))
 
 		vm.Set("dbReport", a.getReportDBFunc(id))
+
+		vm.Set("sendAt", a.getSendAtFunc(id))
+
+		vm.Set("sendAfter", a.getSendAfterFunc(id))
+
+		vm.Set("sendAlbum", a.getSendAlbumFunc(id))
+
+		vm.Set("sendLocation", a.getSendLocationFunc(id))
+
+		vm.Set("sendVenue", a.getSendVenueFunc(id))
+
+		vm.Set("sendContact", a.getSendContactFunc(id))
+
+		vm.Set("sendDice", a.getSendDiceFunc(id))
+
+		vm.Set("sendAnimation", a.getSendAnimationFunc(id))
+
+		vm.Set("sendPoll", a.getSendPollFunc(id))
+
+		vm.Set("sendQuiz", a.getSendQuizFunc(id))
+
+		vm.Set("sendInvoice", a.getSendInvoiceFunc(id))
+
+		vm.Set("banMember", a.getBanMemberFunc(id))
+
+		vm.Set("unbanMember", a.getUnbanMemberFunc(id))
+
+		vm.Set("restrictMember", a.getRestrictMemberFunc(id))
+
+		vm.Set("promoteMember", a.getPromoteMemberFunc(id))
+
+		vm.Set("pinMessage", a.getPinMessageFunc(id))
+
+		vm.Set("unpinMessage", a.getUnpinMessageFunc(id))
+
+		vm.Set("setChatTitle", a.getSetChatTitleFunc(id))
+
+		vm.Set("setChatDescription", a.getSetChatDescriptionFunc(id))
+
+		vm.Set("setChatPhoto", a.getSetChatPhotoFunc(id))
+
+		vm.Set("getChatAdmins", a.getGetChatAdminsFunc(id))
+
+		vm.Set("getChatMemberCount", a.getGetChatMemberCountFunc(id))
+
+		vm.Set("createInviteLink", a.getCreateInviteLinkFunc(id))
+
+		vm.Set("enableCaptcha", a.getEnableCaptchaFunc(id))
+
+		vm.Set("disableCaptcha", a.getDisableCaptchaFunc(id))
+
+		vm.Set("chatAction", a.getChatActionFunc(id))
+
+		vm.Set("setUserTimeout", a.getSetUserTimeoutFunc(id))
+
+		vm.Set("clearUserTimeout", a.getClearUserTimeoutFunc(id))
 	}
 
-	bot, _ := vm.Object("bot")
+	return vm
+}
 
-	return bot
+// callHandler calls bot handler in a vm bound to the chat
+func (a *application) callHandler(id string, handler string, args ...interface{}) (otto.Value, error) {
+	return a.callFunction(id, "bot."+handler, args...)
+}
+
+// callFunction calls js function in a vm bound to the chat, aborting execution once script timeout elapses
+func (a *application) callFunction(id string, function string, args ...interface{}) (result otto.Value, err error) {
+	vm := a.getVm(id)
+	handler := strings.TrimPrefix(function, "bot.")
+
+	if a.scriptTimeout > 0 {
+		timer := time.AfterFunc(a.scriptTimeout, func() {
+			vm.Interrupt(func() {
+				panic(errScriptTimeout)
+			})
+		})
+		defer timer.Stop()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if r != errScriptTimeout {
+				panic(r)
+			}
+			log.Errorf("Handler %s for chat %s was interrupted after %s", handler, id, a.scriptTimeout)
+			err = errScriptTimeout
+		}
+		if err != nil && handler != "onError" {
+			a.onError(id, handler, err)
+		}
+	}()
+
+	return vm.Call(function, args...)
+}
+
+// hasHandler reports whether script defines optional bot handler. Handlers are collected once per template,
+// so that template is not copied on every update
+func (a *application) hasHandler(handler string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.handlers == nil || a.handlersTemplate != a.vmTemplate {
+		a.handlers = botHandlers(a.vmTemplate)
+		a.handlersTemplate = a.vmTemplate
+	}
+
+	return a.handlers[handler]
+}
+
+// botHandlers returns names of functions of bot object, they are read in a copy since running code in template is not safe for concurrent use
+func botHandlers(template Vm) map[string]bool {
+	handlers := map[string]bool{}
+
+	bot, err := template.Copy().Object("bot")
+	if err != nil || bot == nil {
+		return handlers
+	}
+	for _, key := range bot.Keys() {
+		if fn, err := bot.Get(key); err == nil && fn.IsFunction() {
+			handlers[key] = true
+		}
+	}
+
+	return handlers
+}
+
+// onError notifies script about failed handler if it defines bot.onError
+func (a *application) onError(id string, handler string, handlerErr error) {
+	if !a.hasHandler("onError") {
+		return
+	}
+
+	if _, err := a.callHandler(id, "onError", handlerErr.Error(), handler, id); err != nil {
+		log.Error("Error in onError ", err)
+	}
 }
 
 func (a *application) createVmTemplate() Vm {
@@ -314,14 +535,22 @@ func (a *application) createVmTemplate() Vm {
 
 	vm.Set("doPost", a.getDoPostFunc())
 
+	vm.Set("httpRequest", a.getHTTPRequestFunc())
+
 	vm.Set("dbQuery", a.getQueryDBFunc())
 
 	vm.Set("dbExec", a.getExecDBFunc())
 
+	vm.Set("dbTransaction", a.getDBTransactionFunc())
+
 	vm.Set("dbReport", a.getReportDBFunc(""))
 
 	vm.Set("getFileLink", a.getGetFileLinkFunc())
 
+	vm.Set("downloadToAttachments", a.getDownloadToAttachmentsFunc())
+
+	vm.Set("downloadTelegramFile", a.getDownloadTelegramFileFunc())
+
 	vm.Set("replaceOptions", a.getReplaceOptionsFunc())
 
 	vm.Set("deleteMessage", a.getDeleteMessageFunc())
@@ -332,52 +561,129 @@ func (a *application) createVmTemplate() Vm {
 
 	vm.Set("env", a.getEnvFunc())
 
+	vm.Set("require", a.getRequireFunc(""))
+
+	vm.Run("require.cache = {}")
+
 	vm.Set("send", a.getSendFunc(""))
 
 	vm.Set("prompt", a.getPromptFunc(""))
 
+	vm.Set("sendAt", a.getSendAtFunc(""))
+
+	vm.Set("sendAfter", a.getSendAfterFunc(""))
+
+	vm.Set("cancelSend", a.getCancelSendFunc())
+
+	vm.Set("sendAlbum", a.getSendAlbumFunc(""))
+
+	vm.Set("sendLocation", a.getSendLocationFunc(""))
+
+	vm.Set("editLiveLocation", a.getEditLiveLocationFunc())
+
+	vm.Set("stopLiveLocation", a.getStopLiveLocationFunc())
+
+	vm.Set("sendVenue", a.getSendVenueFunc(""))
+
+	vm.Set("sendContact", a.getSendContactFunc(""))
+
+	vm.Set("sendDice", a.getSendDiceFunc(""))
+
+	vm.Set("sendAnimation", a.getSendAnimationFunc(""))
+
+	vm.Set("sendPoll", a.getSendPollFunc(""))
+
+	vm.Set("sendQuiz", a.getSendQuizFunc(""))
+
+	vm.Set("stopPoll", a.getStopPollFunc())
+
+	vm.Set("answerInlineQuery", a.getAnswerInlineQueryFunc())
+
+	vm.Set("sendInvoice", a.getSendInvoiceFunc(""))
+
+	vm.Set("banMember", a.getBanMemberFunc(""))
+
+	vm.Set("unbanMember", a.getUnbanMemberFunc(""))
+
+	vm.Set("restrictMember", a.getRestrictMemberFunc(""))
+
+	vm.Set("promoteMember", a.getPromoteMemberFunc(""))
+
+	vm.Set("pinMessage", a.getPinMessageFunc(""))
+
+	vm.Set("unpinMessage", a.getUnpinMessageFunc(""))
+
+	vm.Set("setChatTitle", a.getSetChatTitleFunc(""))
+
+	vm.Set("setChatDescription", a.getSetChatDescriptionFunc(""))
+
+	vm.Set("setChatPhoto", a.getSetChatPhotoFunc(""))
+
+	vm.Set("getChatAdmins", a.getGetChatAdminsFunc(""))
+
+	vm.Set("getChatMemberCount", a.getGetChatMemberCountFunc(""))
+
+	vm.Set("createInviteLink", a.getCreateInviteLinkFunc(""))
+
+	vm.Set("approveJoinRequest", a.getApproveJoinRequestFunc())
+
+	vm.Set("declineJoinRequest", a.getDeclineJoinRequestFunc())
+
+	vm.Set("enableCaptcha", a.getEnableCaptchaFunc(""))
+
+	vm.Set("disableCaptcha", a.getDisableCaptchaFunc(""))
+
+	vm.Set("chatAction", a.getChatActionFunc(""))
+
+	vm.Set("setUserTimeout", a.getSetUserTimeoutFunc(""))
+
+	vm.Set("clearUserTimeout", a.getClearUserTimeoutFunc(""))
+
 	vm.Set("set", a.getSetFunc(""))
 
 	vm.Set("get", a.getGetFunc(""))
 
 	vm.Set("del", a.getDelFunc(""))
 
+	vm.Set("globalSet", a.getSetFunc(""))
+
+	vm.Set("globalGet", a.getGetFunc(""))
+
+	vm.Set("globalDel", a.getDelFunc(""))
+
+	vm.Set("userSet", a.getUserSetFunc())
+
+	vm.Set("userGet", a.getUserGetFunc())
+
+	vm.Set("userDel", a.getUserDelFunc())
+
 	return vm
 }
 
-func (a *application) onTimer() {
-	_, err := a.GetBot("").Call("onTimer")
-
-	if err != nil {
-		log.Error("Error in onTimer ", err)
+// runScheduled is invoked by scheduler to run a scheduled js function
+func (a *application) runScheduled(function string) {
+	if _, err := a.callFunction("", function); err != nil {
+		log.Error("Error in scheduled "+function+" ", err)
 	}
 }
 
 func (a *application) onInit() {
-	//start timer here when everything is ready
-	if GetEnv("TIMER", "") != "" {
-		duration, err := time.ParseDuration(GetEnv("TIMER", ""))
-		if err != nil {
-			log.Error("Error parsing time duration for timer ", err)
-		} else {
-			ticker := time.NewTicker(duration)
-			go func() {
-				for range ticker.C {
-					a.onTimer()
-				}
-			}()
-		}
-	}
+	//start scheduler and delivery of scheduled messages here when everything is ready
+	a.scheduler.start()
+	a.startDelivery(time.Second)
 
-	_, err := a.GetBot("").Call("onInit")
+	_, err := a.callHandler("", "onInit")
 
 	if err != nil {
 		log.Error("Error in onInit ", err)
 	}
+
+	//challenges of chats captcha is not enabled for in onInit are checked too
+	a.rearmChallenges("")
 }
 
 func (a *application) handleMessage(m *tbot.Message) {
-	_, err := a.GetBot(m.Chat.ID).Call("onMessage", m)
+	_, err := a.callHandler(m.Chat.ID, "onMessage", m)
 
 	if err != nil {
 		log.Error("Error in handleMessage ", err)
@@ -385,13 +691,50 @@ func (a *application) handleMessage(m *tbot.Message) {
 }
 
 func (a *application) handleCallback(cq *tbot.CallbackQuery) {
-	_, err := a.GetBot(cq.Message.Chat.ID).Call("onCallback", cq)
+	_, err := a.callHandler(cq.Message.Chat.ID, "onCallback", cq)
 
 	if err != nil {
 		log.Error("Error in handleCallback ", err)
 	}
 }
 
+var errScriptTimeout = errors.New("Script execution timed out")
+
+var functionNameRegexp = regexp.MustCompile(`^[A-Za-z_$][\w$]*(\.[A-Za-z_$][\w$]*)*$`)
+
+// getScheduleFunc returns schedule function collecting specs to the slice, or registering them right away if it is nil
+func (a *application) getScheduleFunc(collected *[]scheduleSpec) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		result, _ := otto.ToValue(false)
+
+		var spec scheduleSpec
+		spec.spec, _ = call.Argument(0).ToString()
+		spec.function, _ = call.Argument(1).ToString()
+		if call.Argument(2).IsString() {
+			spec.timezone, _ = call.Argument(2).ToString()
+		}
+
+		if !functionNameRegexp.MatchString(spec.function) {
+			log.Error("Error scheduling function, invalid function name ", spec.function)
+			return result
+		}
+
+		if collected != nil {
+			if _, err := newScheduledJob(spec); err != nil {
+				log.Errorf("Error scheduling %s with %q: %v", spec.function, spec.spec, err)
+				return result
+			}
+			*collected = append(*collected, spec)
+		} else if err := a.scheduler.add(sourceRuntime, spec); err != nil {
+			log.Errorf("Error scheduling %s with %q: %v", spec.function, spec.spec, err)
+			return result
+		}
+
+		result, _ = otto.ToValue(true)
+		return result
+	}
+}
+
 func (a *application) getReportDBFunc(userID string) func(call otto.FunctionCall) otto.Value {
 	return func(call otto.FunctionCall) otto.Value {
 		result := otto.Value{}
@@ -435,56 +778,66 @@ func (a *application) getQueryDBFunc() func(call otto.FunctionCall) otto.Value {
 		result := otto.Value{}
 
 		if query, err := call.Argument(0).ToString(); err == nil {
-			var arguments []interface{}
-			for i := 1; i < len(call.ArgumentList); i++ {
-				arg, _ := call.Argument(i).Export()
-				arguments = append(arguments, arg)
-			}
-			rows := a.QueryDB(query, arguments)
-			var out bytes.Buffer
-			out.WriteRune('[')
-			for idx, row := range rows {
-				out.WriteRune('{')
-				i := 1
-				for el := row.Front(); el != nil; el = el.Next() {
-					out.WriteString(strconv.Quote(fmt.Sprintf("%s", el.Key)))
-					out.WriteRune(':')
-					v, _ := json.Marshal(el.Value)
-					out.WriteString(string(v))
-					if i < row.Len() {
-						out.WriteRune(',')
-					}
-					i++
-				}
-				out.WriteRune('}')
-				if idx+1 < len(rows) {
-					out.WriteRune(',')
-				}
-			}
-			out.WriteRune(']')
-			result, _ = otto.ToValue(out.String())
+			rows := a.QueryDB(query, dbArguments(call, 1))
+			result, _ = otto.ToValue(rowsJSON(rows))
 		}
 
 		return result
 	}
 }
 
+// dbArguments exports arguments of db function call starting from index first
+func dbArguments(call otto.FunctionCall, first int) []interface{} {
+	var arguments []interface{}
+	for i := first; i < len(call.ArgumentList); i++ {
+		arg, _ := call.Argument(i).Export()
+		arguments = append(arguments, arg)
+	}
+	return arguments
+}
+
+// rowsJSON encodes rows as json array of objects keeping order of columns
+func rowsJSON(rows []*orderedmap.OrderedMap) string {
+	var out bytes.Buffer
+	out.WriteRune('[')
+	for idx, row := range rows {
+		out.WriteRune('{')
+		i := 1
+		for el := row.Front(); el != nil; el = el.Next() {
+			out.WriteString(strconv.Quote(fmt.Sprintf("%s", el.Key)))
+			out.WriteRune(':')
+			v, _ := json.Marshal(el.Value)
+			out.WriteString(string(v))
+			if i < row.Len() {
+				out.WriteRune(',')
+			}
+			i++
+		}
+		out.WriteRune('}')
+		if idx+1 < len(rows) {
+			out.WriteRune(',')
+		}
+	}
+	out.WriteRune(']')
+	return out.String()
+}
+
+// execResultJSON encodes result of db statement execution
+func execResultJSON(res sql.Result) string {
+	lastInsertId, _ := res.LastInsertId()
+	rowsAffected, _ := res.RowsAffected()
+	return fmt.Sprintf("{ \"lastInsertId\" : \"%d\", \"rowsAffected\" : \"%d\"}", lastInsertId, rowsAffected)
+}
+
 func (a *application) getExecDBFunc() func(call otto.FunctionCall) otto.Value {
 	return func(call otto.FunctionCall) otto.Value {
 		result := otto.Value{}
 
 		if query, err := call.Argument(0).ToString(); err == nil {
-			var arguments []interface{}
-			for i := 1; i < len(call.ArgumentList); i++ {
-				arg, _ := call.Argument(i).Export()
-				arguments = append(arguments, arg)
-			}
-			res := a.ExecDB(query, arguments)
+			res := a.ExecDB(query, dbArguments(call, 1))
 
 			if res != nil {
-				lastInsertId, _ := res.LastInsertId()
-				rowsAffected, _ := res.RowsAffected()
-				result, _ = otto.ToValue(fmt.Sprintf("{ \"lastInsertId\" : \"%d\", \"rowsAffected\" : \"%d\"}", lastInsertId, rowsAffected))
+				result, _ = otto.ToValue(execResultJSON(res))
 			}
 		}
 
@@ -586,10 +939,8 @@ func (a *application) getReplaceOptionsFunc() func(call otto.FunctionCall) otto.
 	return func(call otto.FunctionCall) otto.Value {
 		if chatID, err := call.Argument(0).ToString(); err == nil {
 			if msgID, err := call.Argument(1).ToInteger(); err == nil {
-				if optionsInterface, err := call.Argument(2).Export(); err == nil {
-					if inlineOptions, ok := optionsInterface.([]map[string]interface{}); ok {
-						a.replaceInlineOptions(chatID, int(msgID), inlineOptions)
-					}
+				if inlineOptions, err := exportJSON(call.Otto, call.Argument(2)); err == nil {
+					a.replaceInlineOptions(chatID, int(msgID), inlineOptions)
 				}
 			}
 		}
@@ -615,10 +966,8 @@ func (a *application) getEditMessageFunc() func(call otto.FunctionCall) otto.Val
 		if chatID, err := call.Argument(0).ToString(); err == nil {
 			if msgID, err := call.Argument(1).ToInteger(); err == nil {
 				if text, err := call.Argument(2).ToString(); err == nil {
-					if optionsInterface, err := call.Argument(3).Export(); err == nil {
-						if inlineOptions, ok := optionsInterface.([]map[string]interface{}); ok {
-							a.editMessage(chatID, int(msgID), text, inlineOptions)
-						}
+					if inlineOptions, err := exportJSON(call.Otto, call.Argument(3)); err == nil {
+						a.editMessage(chatID, int(msgID), text, inlineOptions)
 					}
 				}
 			}
@@ -640,15 +989,43 @@ func (a *application) getGetFileLinkFunc() func(call otto.FunctionCall) otto.Val
 	}
 }
 
+// scopeKey builds cache key of a value stored in chat scope or, if chat is not specified, in global scope.
+// Chat ids never contain colon, so keys of different scopes can not collide
+func scopeKey(chatID string, key string) string {
+	if chatID == "" {
+		return "global:" + key
+	}
+	return "chat:" + chatID + ":" + key
+}
+
+func (a *application) setScriptValue(chatID string, keyArg otto.Value, valArg otto.Value) {
+	if keyArg.IsString() && valArg.IsObject() {
+		key, _ := keyArg.ToString()
+		if val, err := valArg.Export(); err == nil {
+			a.setCacheItem(scopeKey(chatID, key), val)
+		}
+	}
+}
+
+func (a *application) getScriptValue(vm *otto.Otto, chatID string, keyArg otto.Value) otto.Value {
+	if keyArg.IsString() {
+		key, _ := keyArg.ToString()
+		return toJsValue(vm, a.getCacheItem(scopeKey(chatID, key)))
+	}
+
+	return otto.Value{}
+}
+
+func (a *application) delScriptValue(chatID string, keyArg otto.Value) {
+	if keyArg.IsString() {
+		key, _ := keyArg.ToString()
+		a.delCacheItem(scopeKey(chatID, key))
+	}
+}
+
 func (a *application) getSetFunc(userID string) func(call otto.FunctionCall) otto.Value {
 	return func(call otto.FunctionCall) otto.Value {
-		if call.Argument(0).IsString() {
-			key, _ := call.Argument(0).ToString()
-			if call.Argument(1).IsObject() {
-				val := call.Argument(1).Object()
-				a.setCacheItem(fmt.Sprintf("%s_%s", userID, key), val)
-			}
-		}
+		a.setScriptValue(userID, call.Argument(0), call.Argument(1))
 
 		return otto.Value{}
 	}
@@ -656,21 +1033,42 @@ func (a *application) getSetFunc(userID string) func(call otto.FunctionCall) ott
 
 func (a *application) getGetFunc(userID string) func(call otto.FunctionCall) otto.Value {
 	return func(call otto.FunctionCall) otto.Value {
-		result := otto.Value{}
-		if call.Argument(0).IsString() {
-			key, _ := call.Argument(0).ToString()
-			result, _ = otto.ToValue(a.getCacheItem(fmt.Sprintf("%s_%s", userID, key)))
+		return a.getScriptValue(call.Otto, userID, call.Argument(0))
+	}
+}
+
+func (a *application) getDelFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		a.delScriptValue(userID, call.Argument(0))
+
+		return otto.Value{}
+	}
+}
+
+func (a *application) getUserSetFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		if chatID, err := call.Argument(0).ToString(); err == nil && call.Argument(0).IsDefined() && chatID != "" {
+			a.setScriptValue(chatID, call.Argument(1), call.Argument(2))
 		}
 
-		return result
+		return otto.Value{}
 	}
 }
 
-func (a *application) getDelFunc(userID string) func(call otto.FunctionCall) otto.Value {
+func (a *application) getUserGetFunc() func(call otto.FunctionCall) otto.Value {
 	return func(call otto.FunctionCall) otto.Value {
-		if call.Argument(0).IsString() {
-			key, _ := call.Argument(0).ToString()
-			a.delCacheItem(fmt.Sprintf("%s_%s", userID, key))
+		if chatID, err := call.Argument(0).ToString(); err == nil && call.Argument(0).IsDefined() && chatID != "" {
+			return a.getScriptValue(call.Otto, chatID, call.Argument(1))
+		}
+
+		return otto.Value{}
+	}
+}
+
+func (a *application) getUserDelFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		if chatID, err := call.Argument(0).ToString(); err == nil && call.Argument(0).IsDefined() && chatID != "" {
+			a.delScriptValue(chatID, call.Argument(1))
 		}
 
 		return otto.Value{}
@@ -708,25 +1106,33 @@ func (a *application) getPromptFunc(userID string) func(call otto.FunctionCall)
 func (a *application) getSendFunc(userID string) func(call otto.FunctionCall) otto.Value {
 	return func(call otto.FunctionCall) otto.Value {
 		var text, attachment, targetUser string
-		var options [][]string
-		var inlineOptions []map[string]interface{}
+		var options interface{}
+		var inlineOptions []interface{}
 
 		if call.Argument(0).IsString() {
 			text, _ = call.Argument(0).ToString()
 			text = strings.TrimSpace(text)
 		}
-		optionsInterface, err := call.Argument(1).Export()
-
-		if err == nil {
-			var ok bool
-			if options, ok = optionsInterface.([][]string); !ok {
-				inlineOptions, _ = optionsInterface.([]map[string]interface{})
-			}
+		if optionsInterface, err := exportJSON(call.Otto, call.Argument(1)); err == nil {
+			options, inlineOptions = parseSendOptions(optionsInterface)
 		}
 
+		var content *fileContent
 		if call.Argument(2).IsString() {
 			attachment, _ = call.Argument(2).ToString()
 			attachment = strings.TrimSpace(attachment)
+		} else if call.Argument(2).IsObject() {
+			//attachment with content or url
+			contentInterface, err := exportJSON(call.Otto, call.Argument(2))
+			if err != nil {
+				log.Error("Error reading attachment ", err)
+				return otto.Value{}
+			}
+			spec, _ := contentInterface.(map[string]interface{})
+			if content, err = parseFileContent(spec); err != nil {
+				log.Error("Error reading attachment ", err)
+				return otto.Value{}
+			}
 		}
 
 		targetUser = userID
@@ -736,7 +1142,12 @@ func (a *application) getSendFunc(userID string) func(call otto.FunctionCall) ot
 			}
 		}
 
-		id := a.sendMessage(targetUser, text, options, inlineOptions, attachment)
+		var id int
+		if content != nil {
+			id = a.sendContent(targetUser, text, options, inlineOptions, content)
+		} else {
+			id = a.sendMessage(targetUser, text, options, inlineOptions, attachment)
+		}
 
 		result, _ := otto.ToValue(id)
 
@@ -744,6 +1155,41 @@ func (a *application) getSendFunc(userID string) func(call otto.FunctionCall) ot
 	}
 }
 
+// parseSendOptions splits options argument of send into custom keyboard and inline keyboard rows.
+// Custom keyboard is either a list of rows or an object with keyboard rows and keyboard settings.
+// Inline row is either a map of text to callback data or a list of button objects
+func parseSendOptions(optionsInterface interface{}) (options interface{}, inlineOptions []interface{}) {
+	if spec, ok := optionsInterface.(map[string]interface{}); ok {
+		_, hasKeyboard := spec["keyboard"]
+		if _, ok := uploadActionOption(spec); ok || hasKeyboard {
+			return spec, nil
+		}
+	}
+
+	var replyRows []interface{}
+	rows, _ := toSlice(optionsInterface)
+	for _, row := range rows {
+		if theMap, ok := row.(map[string]interface{}); ok {
+			inlineOptions = append(inlineOptions, theMap)
+			continue
+		}
+		buttons, ok := toSlice(row)
+		if !ok {
+			continue
+		}
+		if isInlineRow(buttons) {
+			inlineOptions = append(inlineOptions, buttons)
+		} else {
+			replyRows = append(replyRows, buttons)
+		}
+	}
+	if len(replyRows) > 0 {
+		options = replyRows
+	}
+
+	return
+}
+
 func (a *application) promptUser(userID string, text string, attachment string) int {
 
 	defer func() {
@@ -798,7 +1244,7 @@ func (a *application) promptUser(userID string, text string, attachment string)
 	return id
 }
 
-func (a *application) sendMessage(userID string, text string, options [][]string, inlineOptions []map[string]interface{}, attachment string) int {
+func (a *application) sendMessage(userID string, text string, options interface{}, inlineOptions interface{}, attachment string) int {
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -806,10 +1252,13 @@ func (a *application) sendMessage(userID string, text string, options [][]string
 		}
 	}()
 
+	if isRemoteURL(attachment) {
+		return a.sendContent(userID, text, options, inlineOptions, &fileContent{URL: attachment, Type: urlFileType(attachment)})
+	}
+
 	attachmentFile := filepath.Join(a.attachmentsDir, attachment)
 	hasAttachment := attachment != "" && FileExists(attachmentFile)
-	hasOptions := len(options) > 0
-	hasInlineOptions := len(inlineOptions) > 0
+	option, hasKeyboard := keyboardOption(options, inlineOptions)
 
 	var id int
 	var err error
@@ -817,139 +1266,38 @@ func (a *application) sendMessage(userID string, text string, options [][]string
 	if hasAttachment {
 		//file uploading
 		fileType := GetFileType(attachmentFile)
-		if hasOptions {
-			if fileType == PHOTO {
-				id, err = a.tgClient.AttachPhoto(userID, attachmentFile, text, tbot.OptReplyKeyboardMarkup(
-					buildReplyOptions(options),
-				))
-			} else if fileType == VIDEO {
-				id, err = a.tgClient.AttachVideo(userID, attachmentFile, text, tbot.OptReplyKeyboardMarkup(
-					buildReplyOptions(options),
-				))
-			} else if fileType == AUDIO {
-				id, err = a.tgClient.AttachAudio(userID, attachmentFile, text, tbot.OptReplyKeyboardMarkup(
-					buildReplyOptions(options),
-				))
-			} else {
-				id, err = a.tgClient.AttachFile(userID, attachmentFile, text, tbot.OptReplyKeyboardMarkup(
-					buildReplyOptions(options),
-				))
-			}
-
-		} else if hasInlineOptions {
-			if fileType == PHOTO {
-				id, err = a.tgClient.AttachPhoto(userID, attachmentFile, text, tbot.OptInlineKeyboardMarkup(
-					buildInlineOptions(inlineOptions),
-				))
-			} else if fileType == VIDEO {
-				id, err = a.tgClient.AttachVideo(userID, attachmentFile, text, tbot.OptInlineKeyboardMarkup(
-					buildInlineOptions(inlineOptions),
-				))
-			} else if fileType == AUDIO {
-				id, err = a.tgClient.AttachAudio(userID, attachmentFile, text, tbot.OptInlineKeyboardMarkup(
-					buildInlineOptions(inlineOptions),
-				))
-			} else {
-				id, err = a.tgClient.AttachFile(userID, attachmentFile, text, tbot.OptInlineKeyboardMarkup(
-					buildInlineOptions(inlineOptions),
-				))
-			}
+		stopUploadProgress := a.showUploadProgress(userID, uploadAction(fileType), fileSize(attachmentFile), options)
+		if fileType == PHOTO {
+			id, err = a.tgClient.AttachPhoto(userID, attachmentFile, text, option)
+		} else if fileType == VIDEO {
+			id, err = a.tgClient.AttachVideo(userID, attachmentFile, text, option)
+		} else if fileType == AUDIO {
+			id, err = a.tgClient.AttachAudio(userID, attachmentFile, text, option)
 		} else {
-			if fileType == PHOTO {
-				id, err = a.tgClient.AttachPhoto(userID, attachmentFile, text, tbot.OptReplyKeyboardRemove)
-			} else if fileType == VIDEO {
-				id, err = a.tgClient.AttachVideo(userID, attachmentFile, text, tbot.OptReplyKeyboardRemove)
-			} else if fileType == AUDIO {
-				id, err = a.tgClient.AttachAudio(userID, attachmentFile, text, tbot.OptReplyKeyboardRemove)
-			} else {
-				id, err = a.tgClient.AttachFile(userID, attachmentFile, text, tbot.OptReplyKeyboardRemove)
-			}
+			id, err = a.tgClient.AttachFile(userID, attachmentFile, text, option)
 		}
+		stopUploadProgress()
 	} else if attachment != "" {
 		//file forwarding
 		fileParts := strings.Split(attachment, ":")
 		if len(fileParts) == 2 {
 			//file type is specified
 			fileType := ParseFileType(fileParts[1])
-			if hasOptions {
-				if fileType == PHOTO {
-					id, err = a.tgClient.ForwardPhoto(userID, fileParts[0], text, tbot.OptReplyKeyboardMarkup(
-						buildReplyOptions(options),
-					))
-				} else if fileType == VIDEO {
-					id, err = a.tgClient.ForwardVideo(userID, fileParts[0], text, tbot.OptReplyKeyboardMarkup(
-						buildReplyOptions(options),
-					))
-				} else if fileType == AUDIO {
-					id, err = a.tgClient.ForwardAudio(userID, fileParts[0], text, tbot.OptReplyKeyboardMarkup(
-						buildReplyOptions(options),
-					))
-				} else {
-					id, err = a.tgClient.ForwardFile(userID, fileParts[0], text, tbot.OptReplyKeyboardMarkup(
-						buildReplyOptions(options),
-					))
-				}
-			} else if hasInlineOptions {
-				if fileType == PHOTO {
-					id, err = a.tgClient.ForwardPhoto(userID, fileParts[0], text, tbot.OptInlineKeyboardMarkup(
-						buildInlineOptions(inlineOptions),
-					))
-				} else if fileType == VIDEO {
-					id, err = a.tgClient.ForwardVideo(userID, fileParts[0], text, tbot.OptInlineKeyboardMarkup(
-						buildInlineOptions(inlineOptions),
-					))
-				} else if fileType == AUDIO {
-					id, err = a.tgClient.ForwardAudio(userID, fileParts[0], text, tbot.OptInlineKeyboardMarkup(
-						buildInlineOptions(inlineOptions),
-					))
-				} else {
-					id, err = a.tgClient.ForwardFile(userID, fileParts[0], text, tbot.OptInlineKeyboardMarkup(
-						buildInlineOptions(inlineOptions),
-					))
-				}
+			if fileType == PHOTO {
+				id, err = a.tgClient.ForwardPhoto(userID, fileParts[0], text, option)
+			} else if fileType == VIDEO {
+				id, err = a.tgClient.ForwardVideo(userID, fileParts[0], text, option)
+			} else if fileType == AUDIO {
+				id, err = a.tgClient.ForwardAudio(userID, fileParts[0], text, option)
 			} else {
-				if fileType == PHOTO {
-					id, err = a.tgClient.ForwardPhoto(userID, fileParts[0], text, tbot.OptReplyKeyboardRemove)
-				} else if fileType == VIDEO {
-					id, err = a.tgClient.ForwardVideo(userID, fileParts[0], text, tbot.OptReplyKeyboardRemove)
-				} else if fileType == AUDIO {
-					id, err = a.tgClient.ForwardAudio(userID, fileParts[0], text, tbot.OptReplyKeyboardRemove)
-				} else {
-					id, err = a.tgClient.ForwardFile(userID, fileParts[0], text, tbot.OptReplyKeyboardRemove)
-				}
+				id, err = a.tgClient.ForwardFile(userID, fileParts[0], text, option)
 			}
 		} else {
 			//send generic document
-			if hasOptions {
-				id, err = a.tgClient.ForwardFile(userID, attachment, text, tbot.OptReplyKeyboardMarkup(
-					buildReplyOptions(options),
-				))
-			} else if hasInlineOptions {
-				id, err = a.tgClient.ForwardFile(userID, attachment, text, tbot.OptInlineKeyboardMarkup(
-					buildInlineOptions(inlineOptions),
-				))
-			} else {
-				id, err = a.tgClient.ForwardFile(userID, attachment, text, tbot.OptReplyKeyboardRemove)
-			}
+			id, err = a.tgClient.ForwardFile(userID, attachment, text, option)
 		}
-	} else if hasOptions {
-		id, err = a.tgClient.SendText(
-			userID,
-			text,
-			tbot.OptReplyKeyboardMarkup(
-				buildReplyOptions(options),
-			),
-		)
-	} else if hasInlineOptions {
-		id, err = a.tgClient.SendText(
-			userID,
-			text,
-			tbot.OptInlineKeyboardMarkup(
-				buildInlineOptions(inlineOptions),
-			),
-		)
-	} else if strings.TrimSpace(text) != "" {
-		id, err = a.tgClient.SendText(userID, text, tbot.OptReplyKeyboardRemove)
+	} else if hasKeyboard || strings.TrimSpace(text) != "" {
+		id, err = a.tgClient.SendText(userID, text, option)
 	} else {
 		log.Warn("Ignoring empty response")
 	}
@@ -961,59 +1309,109 @@ func (a *application) sendMessage(userID string, text string, options [][]string
 	return id
 }
 
-func buildReplyOptions(replyOptions [][]string) *tbot.ReplyKeyboardMarkup {
-	keyboard := make([][]tbot.KeyboardButton, len(replyOptions))
-	for i := range replyOptions {
-		keyboard[i] = make([]tbot.KeyboardButton, len(replyOptions[i]))
-		for j := range replyOptions[i] {
-			keyboard[i][j] = tbot.KeyboardButton{Text: replyOptions[i][j]}
-		}
+// exportJSON exports js value through json, since otto fails to export arrays of mixed elements
+func exportJSON(vm *otto.Otto, val otto.Value) (interface{}, error) {
+	if !val.IsObject() {
+		return nil, nil
 	}
 
-	return &tbot.ReplyKeyboardMarkup{
-		Keyboard:        keyboard,
-		OneTimeKeyboard: true,
-		ResizeKeyboard:  true,
+	data, err := vm.Call("JSON.stringify", nil, val)
+	if err != nil {
+		return nil, err
 	}
 
+	var options interface{}
+	err = json.Unmarshal([]byte(data.String()), &options)
+
+	return options, err
 }
 
-func buildInlineOptions(inlineOptions []map[string]interface{}) *tbot.InlineKeyboardMarkup {
-	keyboard := make([][]tbot.InlineKeyboardButton, len(inlineOptions))
+// toJsValue converts stored value to a native js value, so that scripts get a fresh object on each read
+func toJsValue(vm *otto.Otto, val interface{}) otto.Value {
+	if val == nil {
+		return otto.Value{}
+	}
 
-	for i := range inlineOptions {
-		theMap := inlineOptions[i]
+	data, err := json.Marshal(val)
+	if err != nil {
+		log.Error("Error converting value to js ", err)
+		return otto.Value{}
+	}
+
+	result, err := vm.Call("JSON.parse", nil, string(data))
+	if err != nil {
+		log.Error("Error converting value to js ", err)
+		return otto.Value{}
+	}
+
+	return result
+}
+
+// buildReplyOptions builds custom keyboard from options in any form accepted by parseSendOptions,
+// keyboard is one-time and resized unless settings say otherwise
+func buildReplyOptions(options interface{}) *ReplyKeyboardMarkup {
+	markup := &ReplyKeyboardMarkup{
+		Keyboard:        [][]KeyboardButton{},
+		OneTimeKeyboard: true,
+		ResizeKeyboard:  true,
+	}
 
-		vals := make([]string, 0, len(theMap))
-		theReversedMap := map[string]string{}
-		for key, val := range theMap {
-			theValue := fmt.Sprintf("%s", val)
-			theReversedMap[theValue] = key
-			vals = append(vals, theValue)
+	var rows []interface{}
+	if spec, ok := options.(map[string]interface{}); ok {
+		//all rows of explicit keyboard are custom keyboard rows
+		rows, _ = toSlice(spec["keyboard"])
+		if oneTime, ok := spec["one_time"].(bool); ok {
+			markup.OneTimeKeyboard = oneTime
 		}
-		sort.Strings(vals)
+		if resize, ok := spec["resize"].(bool); ok {
+			markup.ResizeKeyboard = resize
+		}
+		markup.IsPersistent = boolField(spec, "persistent")
+		markup.Selective = boolField(spec, "selective")
+		markup.InputFieldPlaceholder, _ = stringField(spec, "placeholder")
+	} else {
+		replyRows, _ := parseSendOptions(options)
+		rows, _ = replyRows.([]interface{})
+	}
 
-		j := 0
-		keyboard[i] = make([]tbot.InlineKeyboardButton, len(theMap))
-		for _, val := range vals {
-			if isValidUrl(val) {
-				button := tbot.InlineKeyboardButton{
-					Text: theReversedMap[val],
-					URL:  val,
-				}
-				keyboard[i][j] = button
+	for _, row := range rows {
+		buttons, ok := toSlice(row)
+		if !ok {
+			continue
+		}
+		keyboardRow := make([]KeyboardButton, len(buttons))
+		for i, button := range buttons {
+			if theMap, ok := button.(map[string]interface{}); ok {
+				keyboardRow[i] = buildReplyButton(theMap)
 			} else {
-				button := tbot.InlineKeyboardButton{
-					Text:         theReversedMap[val],
-					CallbackData: val,
-				}
-				keyboard[i][j] = button
+				keyboardRow[i] = KeyboardButton{Text: fmt.Sprintf("%v", button)}
+			}
+		}
+		markup.Keyboard = append(markup.Keyboard, keyboardRow)
+	}
+
+	return markup
+}
+
+// buildInlineOptions builds inline keyboard from map rows and inline rows of button objects, used by replaceOptions and editMessage as is,
+// rows of plain button objects are custom keyboard rows and are skipped like in send
+func buildInlineOptions(inlineOptions interface{}) *InlineKeyboardMarkup {
+	rows, _ := toSlice(inlineOptions)
+
+	keyboard := make([][]InlineKeyboardButton, 0, len(rows))
+	for _, row := range rows {
+		if theMap, ok := row.(map[string]interface{}); ok {
+			keyboard = append(keyboard, buildLegacyInlineRow(theMap))
+		} else if buttons, ok := toSlice(row); ok && isInlineRow(buttons) {
+			keyboardRow := make([]InlineKeyboardButton, len(buttons))
+			for i, button := range buttons {
+				keyboardRow[i] = buildInlineButton(button.(map[string]interface{}))
 			}
-			j++
+			keyboard = append(keyboard, keyboardRow)
 		}
 	}
 
-	return &tbot.InlineKeyboardMarkup{
+	return &InlineKeyboardMarkup{
 		InlineKeyboard: keyboard,
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/ReneKroon/ttlcache"
@@ -29,7 +30,7 @@ var (
 
 func TestSetCacheItem(t *testing.T) {
 	cache := ttlcache.NewCache()
-	a := &application{cache: cache}
+	a := &application{cache: &memoryStore{cache: cache}}
 	a.setCacheItem("test", "value")
 
 	if _, ok := cache.Get("test"); !ok {
@@ -39,7 +40,7 @@ func TestSetCacheItem(t *testing.T) {
 
 func TestGetCacheItem(t *testing.T) {
 	cache := ttlcache.NewCache()
-	a := &application{cache: cache}
+	a := &application{cache: &memoryStore{cache: cache}}
 	cache.Set("test", "value")
 
 	if val := a.getCacheItem("test"); val == nil {
@@ -49,7 +50,7 @@ func TestGetCacheItem(t *testing.T) {
 
 func TestDelCacheItem(t *testing.T) {
 	cache := ttlcache.NewCache()
-	a := &application{cache: cache}
+	a := &application{cache: &memoryStore{cache: cache}}
 	cache.Set("test", "value")
 	a.delCacheItem("test")
 
@@ -102,6 +103,10 @@ func (VmStub) Run(src interface{}) (otto.Value, error) {
 	return otto.Value{}, nil
 }
 
+func (VmStub) Compile(filename string, src interface{}) (*otto.Script, error) {
+	return nil, nil
+}
+
 func (VmStub) Call(source string, argumentList ...interface{}) (otto.Value, error) {
 	return otto.Value{}, nil
 }
@@ -114,6 +119,9 @@ func (VmStub) Copy() Vm {
 	return &VmStub{}
 }
 
+func (VmStub) Interrupt(f func()) {
+}
+
 func TestReplaceInlineOptions(t *testing.T) {
 	telebot := &mocks.Telebot{}
 
@@ -449,3 +457,85 @@ func TestQueryDB(t *testing.T) {
 	}
 
 }
+
+func TestHasHandler(t *testing.T) {
+	vm := VmFactoryImpl{}.GetVm()
+	vm.Run(`bot = {onMessage: function () {}, version: 1}`)
+	a := &application{vmTemplate: vm}
+
+	assert.True(t, a.hasHandler("onMessage"))
+	assert.False(t, a.hasHandler("version"))
+	assert.False(t, a.hasHandler("onCallback"))
+
+	//handlers are collected again once template is replaced
+	reloaded := VmFactoryImpl{}.GetVm()
+	reloaded.Run(`bot = {onCallback: function () {}}`)
+	a.vmTemplate = reloaded
+
+	assert.False(t, a.hasHandler("onMessage"))
+	assert.True(t, a.hasHandler("onCallback"))
+}
+
+func TestCallHandlerTimeout(t *testing.T) {
+	vm := VmFactoryImpl{}.GetVm()
+	var reported []string
+	vm.Set("report", func(call otto.FunctionCall) otto.Value {
+		for _, arg := range call.ArgumentList {
+			reported = append(reported, arg.String())
+		}
+		return otto.Value{}
+	})
+	vm.Run(`bot = {
+		onTimer: function () { while (true) {} },
+		onInit: function () { return 1 },
+		onError: function (error, handler, chatID) { report(error, handler) }
+	}`)
+
+	a := &application{vmTemplate: vm, scriptTimeout: time.Millisecond * 50}
+
+	_, err := a.callHandler("", "onTimer")
+
+	assert.Equal(t, errScriptTimeout, err)
+	assert.Equal(t, []string{errScriptTimeout.Error(), "onTimer"}, reported)
+
+	//handler finished in time
+	res, err := a.callHandler("", "onInit")
+
+	assert.Nil(t, err)
+	val, _ := res.ToInteger()
+	assert.Equal(t, int64(1), val)
+}
+
+func TestScopeKey(t *testing.T) {
+	assert.Equal(t, "global:step", scopeKey("", "step"))
+	assert.Equal(t, "chat:-100:step", scopeKey("-100", "step"))
+	assert.NotEqual(t, scopeKey("1", "2_step"), scopeKey("1_2", "step"))
+}
+
+func TestGlobalAndUserScope(t *testing.T) {
+	a := &application{cache: newMemoryStore(time.Minute)}
+	vm := otto.New()
+	vm.Set("set", a.getSetFunc(chatID))
+	vm.Set("get", a.getGetFunc(chatID))
+	vm.Set("globalSet", a.getSetFunc(""))
+	vm.Set("globalGet", a.getGetFunc(""))
+	vm.Set("globalDel", a.getDelFunc(""))
+	vm.Set("userGet", a.getUserGetFunc())
+	vm.Set("userSet", a.getUserSetFunc())
+	vm.Set("userDel", a.getUserDelFunc())
+
+	val, err := vm.Run(`
+		set("counter", {n: 1})
+		globalSet("counter", {n: 10})
+		userSet("456", "counter", {n: 100})
+		get("counter").n + globalGet("counter").n + userGet("456", "counter").n + userGet("` + chatID + `", "counter").n`)
+
+	assert.Nil(t, err)
+	res, _ := val.ToInteger()
+	assert.Equal(t, int64(112), res)
+
+	val, err = vm.Run(`globalDel("counter"); userDel("456", "counter"); typeof globalGet("counter") + "," + typeof userGet("456", "counter")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "undefined,undefined", val.String())
+}
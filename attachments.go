@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/h2non/filetype"
+	"github.com/labstack/gommon/log"
+)
+
+const defaultContentName = "attachment"
+
+// fileContent is attachment which is not stored in attachments directory,
+// either content generated by script or url of file telegram downloads itself
+type fileContent struct {
+	Name    string
+	Content []byte
+	URL     string
+	Type    FileType
+}
+
+// isRemoteURL reports whether attachment is http or https url
+func isRemoteURL(attachment string) bool {
+	return (strings.HasPrefix(attachment, "http://") || strings.HasPrefix(attachment, "https://")) && isValidUrl(attachment)
+}
+
+// urlFileType guesses type of file by extension in url path
+func urlFileType(aURL string) FileType {
+	u, err := url.Parse(aURL)
+	if err != nil {
+		return OTHER
+	}
+
+	kind := filetype.GetType(strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), ".")))
+	switch kind.MIME.Type {
+	case "image":
+		return PHOTO
+	case "video":
+		return VIDEO
+	case "audio":
+		return AUDIO
+	default:
+		return OTHER
+	}
+}
+
+// parseFileContent reads attachment object of send: {url, type} or {name, content, type, encoding},
+// content is text or base64 encoded binary if encoding is base64. Type is detected if not specified
+func parseFileContent(spec map[string]interface{}) (*fileContent, error) {
+	fileType, hasType := stringField(spec, "type")
+
+	if aURL, ok := stringField(spec, "url"); ok {
+		if !isRemoteURL(aURL) {
+			return nil, fmt.Errorf("Invalid attachment url %q", aURL)
+		}
+		content := &fileContent{URL: aURL, Type: urlFileType(aURL)}
+		if hasType {
+			content.Type = ParseFileType(fileType)
+		}
+		return content, nil
+	}
+
+	text, ok := stringField(spec, "content")
+	if !ok {
+		return nil, fmt.Errorf("Attachment url or content is missing")
+	}
+
+	content := &fileContent{Name: defaultContentName, Content: []byte(text)}
+	if encoding, _ := stringField(spec, "encoding"); encoding == "base64" {
+		data, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, err
+		}
+		content.Content = data
+	}
+	if name, ok := stringField(spec, "name"); ok {
+		content.Name = name
+	}
+	if hasType {
+		content.Type = ParseFileType(fileType)
+	} else {
+		content.Type = DetectFileType(content.Content)
+	}
+
+	return content, nil
+}
+
+// sendContent sends in-memory content or file by url, keyboard is chosen the same way as in sendMessage
+func (a *application) sendContent(userID string, text string, options interface{}, inlineOptions interface{}, content *fileContent) int {
+	option, _ := keyboardOption(options, inlineOptions)
+
+	var id int
+	var err error
+
+	if content.URL != "" {
+		//url is passed instead of file id, telegram downloads the file
+		if content.Type == PHOTO {
+			id, err = a.tgClient.ForwardPhoto(userID, content.URL, text, option)
+		} else if content.Type == VIDEO {
+			id, err = a.tgClient.ForwardVideo(userID, content.URL, text, option)
+		} else if content.Type == AUDIO {
+			id, err = a.tgClient.ForwardAudio(userID, content.URL, text, option)
+		} else {
+			id, err = a.tgClient.ForwardFile(userID, content.URL, text, option)
+		}
+	} else {
+		stopUploadProgress := a.showUploadProgress(userID, uploadAction(content.Type), int64(len(content.Content)), options)
+		defer stopUploadProgress()
+
+		id, err = a.tgClient.AttachContent(userID, mediaType(content.Type), content.Name, content.Content, text, option)
+	}
+
+	if err != nil {
+		log.Error("Error sending message ", err)
+	}
+
+	return id
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSendContent(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, attachmentsDir: attachmentsDir}
+	vm := otto.New()
+	vm.Set("send", a.getSendFunc("42"))
+	vm.Set("chart", base64.StdEncoding.EncodeToString(pngHeader))
+
+	telebot.On("AttachContent", "42", "document", "report.csv", []byte("id,total\n1,100\n"), "Report", mock.AnythingOfType("func(url.Values)")).Return(1, nil)
+	telebot.On("AttachContent", "42", "photo", "attachment", pngHeader, "Chart", mock.AnythingOfType("func(url.Values)")).Return(2, nil)
+	telebot.On("AttachContent", "42", "audio", "note.txt", []byte("text"), "", mock.AnythingOfType("func(url.Values)")).Return(3, nil)
+	telebot.On("ForwardPhoto", "42", "https://example.com/charts/sales.PNG", "Sales", mock.AnythingOfType("func(url.Values)")).Return(4, nil)
+	telebot.On("ForwardVideo", "42", "https://example.com/render?id=1", "", mock.AnythingOfType("func(url.Values)")).Return(5, nil)
+	telebot.On("ForwardFile", "42", "https://example.com/files/contract", "Contract", mock.AnythingOfType("func(url.Values)")).Return(6, nil)
+
+	val, err := vm.Run(`[
+		send("Report", null, {name: "report.csv", content: "id,total\n1,100\n"}),
+		send("Chart", null, {content: chart, encoding: "base64"}),
+		send("", null, {name: "note.txt", content: "text", type: "audio"}),
+		send("Sales", null, "https://example.com/charts/sales.PNG"),
+		send("", null, {url: "https://example.com/render?id=1", type: "video"}),
+		send("Contract", null, "https://example.com/files/contract"),
+		send("Invalid", null, {url: "ftp://example.com/file"}),
+		send("Invalid", null, {content: "%%%", encoding: "base64"})
+	].join(",")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1,2,3,4,5,6,,", val.String())
+	telebot.AssertExpectations(t)
+}
+
+func TestSendContentUploadAction(t *testing.T) {
+	defaultInterval := uploadActionInterval
+	uploadActionInterval = 10 * time.Millisecond
+	defer func() { uploadActionInterval = defaultInterval }()
+
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, uploadActionSize: 1}
+
+	telebot.On("SendAction", "42", "upload_document").Return(nil)
+	telebot.On("AttachContent", "42", "document", "report.csv", []byte("id\n1\n"), "Report", mock.AnythingOfType("func(url.Values)")).Return(1, nil).
+		Run(func(args mock.Arguments) { time.Sleep(55 * time.Millisecond) })
+
+	a.sendContent("42", "Report", nil, nil, &fileContent{Name: "report.csv", Content: []byte("id\n1\n"), Type: OTHER})
+
+	//action is repeated during upload and stopped after it
+	calls := len(telebot.Calls)
+	time.Sleep(30 * time.Millisecond)
+
+	telebot.AssertExpectations(t)
+	assert.True(t, calls >= 4, calls)
+	assert.Equal(t, calls, len(telebot.Calls))
+}
+
+func TestReportDB(t *testing.T) {
+	db, dbMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	dbMock.ExpectQuery("SELECT id, name FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("1", "John").AddRow("2", "Jane"))
+
+	telebot := &mocks.Telebot{}
+	telebot.On("AttachContent", "42", "document", "users.csv", []byte("id,name\n1,John\n2,Jane\n"), "Users", mock.AnythingOfType("func(url.Values)")).Return(7, nil)
+
+	a := &application{tgClient: telebot, dbClient: db, attachmentsDir: attachmentsDir}
+	id := a.ReportDB("42", "Users", "SELECT id, name FROM users", "users", nil)
+
+	assert.Equal(t, 7, id)
+	telebot.AssertExpectations(t)
+}
+
+func TestAttachContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bottoken/sendDocument", r.URL.Path)
+		assert.Equal(t, "42", r.FormValue("chat_id"))
+		assert.Equal(t, "Report", r.FormValue("caption"))
+		assert.Equal(t, "HTML", r.FormValue("parse_mode"))
+
+		file, header, err := r.FormFile("document")
+		assert.Nil(t, err)
+		assert.Equal(t, "report.csv", header.Filename)
+		content, _ := ioutil.ReadAll(file)
+		assert.Equal(t, "id\n1\n", string(content))
+
+		rw.Write([]byte(`{"ok":true,"result":{"message_id":9}}`))
+	}))
+	defer server.Close()
+
+	defaultURL := apiBaseURL
+	apiBaseURL = server.URL
+	defer func() { apiBaseURL = defaultURL }()
+
+	wrapper := &TbotWrapper{token: "token"}
+	id, err := wrapper.AttachContent("42", "document", "report.csv", []byte("id\n1\n"), "Report", optReplyMarkup(buildInlineOptions(nil)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 9, id)
+}
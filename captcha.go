@@ -0,0 +1,474 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+	"github.com/yanzay/tbot/v2"
+)
+
+// prefix of callback data of captcha buttons, such callbacks are not passed to bot.onCallback
+const captchaPrefix = "captcha:"
+
+const (
+	defaultCaptchaTimeout    = 2 * time.Minute
+	defaultCaptchaText       = "{name}, press the button within {seconds} seconds to be able to write in this chat"
+	defaultCaptchaMathText   = "{name}, solve {question} within {seconds} seconds to be able to write in this chat"
+	defaultCaptchaButtonText = "I'm not a robot"
+)
+
+// captchaSettings configures verification of new members of a chat, texts may contain {name}, {seconds} and {question} placeholders
+type captchaSettings struct {
+	challenge string
+	timeout   time.Duration
+	text      string
+	button    string
+	success   string
+	fail      string
+}
+
+// captchaChallenge is a challenge pending to be answered by a new member
+type captchaChallenge struct {
+	settings  *captchaSettings
+	member    tbot.User
+	messageID int
+	answer    string
+	deadline  time.Time
+	timer     *time.Timer
+}
+
+// storedChallenge is a challenge kept in session store, so that it can be answered after restart
+type storedChallenge struct {
+	Member    tbot.User `json:"member"`
+	MessageID int       `json:"message_id"`
+	Answer    string    `json:"answer"`
+	Deadline  int64     `json:"deadline"`
+}
+
+// CaptchaResult is passed to bot.onCaptcha once new member passes or fails verification
+type CaptchaResult struct {
+	ChatID string
+	User   tbot.User
+	Passed bool
+}
+
+func captchaKey(chatID string, userID int) string {
+	return chatID + "|" + strconv.Itoa(userID)
+}
+
+// captchaStoreKey is a key of challenge in session store, it does not clash with keys of script values
+func captchaStoreKey(key string) string {
+	return "captcha|" + key
+}
+
+// captchaSettingsFromObject reads settings object of enableCaptcha: type (button or math), timeout, text, button, success and fail
+func captchaSettingsFromObject(obj map[string]interface{}) (*captchaSettings, error) {
+	settings := &captchaSettings{challenge: "button", timeout: defaultCaptchaTimeout, button: defaultCaptchaButtonText}
+
+	if challenge, ok := stringField(obj, "type"); ok {
+		if challenge != "button" && challenge != "math" {
+			return nil, fmt.Errorf("Unsupported captcha type %s", challenge)
+		}
+		settings.challenge = challenge
+	}
+	if timeout, ok := obj["timeout"]; ok {
+		val, _ := otto.ToValue(timeout)
+		delay, err := parseDelay(val)
+		if err != nil {
+			return nil, err
+		}
+		settings.timeout = delay
+	}
+	settings.text, _ = stringField(obj, "text")
+	if settings.text == "" && settings.challenge == "math" {
+		settings.text = defaultCaptchaMathText
+	} else if settings.text == "" {
+		settings.text = defaultCaptchaText
+	}
+	if button, ok := stringField(obj, "button"); ok {
+		settings.button = button
+	}
+	settings.success, _ = stringField(obj, "success")
+	settings.fail, _ = stringField(obj, "fail")
+
+	return settings, nil
+}
+
+// captchaText substitutes placeholders of captcha text
+func captchaText(text string, member tbot.User, settings *captchaSettings, question string) string {
+	return strings.NewReplacer(
+		"{name}", html.EscapeString(member.FirstName),
+		"{seconds}", strconv.Itoa(int(settings.timeout.Seconds())),
+		"{question}", question,
+	).Replace(text)
+}
+
+// mathChallenge returns arithmetic question, its answer and answer options in random order
+func mathChallenge(rnd *rand.Rand) (string, int, []int) {
+	x, y := rnd.Intn(9)+1, rnd.Intn(9)+1
+	answer := x + y
+
+	options := []int{answer}
+	for len(options) < 4 {
+		option := answer + rnd.Intn(11) - 5
+		duplicate := option <= 0
+		for _, o := range options {
+			duplicate = duplicate || o == option
+		}
+		if !duplicate {
+			options = append(options, option)
+		}
+	}
+	rnd.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+
+	return fmt.Sprintf("%d + %d", x, y), answer, options
+}
+
+// enableCaptcha sets captcha settings of the chat and re-arms its challenges sent before restart
+func (a *application) enableCaptcha(chatID string, settings *captchaSettings) {
+	a.captchaMu.Lock()
+	if a.captchas == nil {
+		a.captchas = map[string]*captchaSettings{}
+	}
+	a.captchas[chatID] = settings
+	a.captchaMu.Unlock()
+
+	a.rearmChallenges(chatID)
+}
+
+func (a *application) disableCaptcha(chatID string) {
+	a.captchaMu.Lock()
+	defer a.captchaMu.Unlock()
+
+	delete(a.captchas, chatID)
+}
+
+// verifyMember starts verification of a member joined a chat with captcha enabled, and cancels it if the member leaves
+func (a *application) verifyMember(cmu *ChatMemberUpdated) {
+	if cmu.NewChatMember == nil || cmu.NewChatMember.User.IsBot {
+		return
+	}
+	member := cmu.NewChatMember.User
+	chatID := cmu.Chat.ID
+
+	switch memberEvent(cmu) {
+	case "joined":
+		a.captchaMu.Lock()
+		settings := a.captchas[chatID]
+		a.captchaMu.Unlock()
+
+		if settings != nil {
+			a.startCaptcha(chatID, member, settings)
+		}
+	case "left", "banned":
+		if challenge := a.takeChallenge(chatID, member.ID, nil); challenge != nil && challenge.messageID != 0 {
+			a.deleteMessage(chatID, challenge.messageID)
+		}
+	}
+}
+
+// startCaptcha restricts the member and sends challenge, member is kicked unless the challenge is answered within timeout
+func (a *application) startCaptcha(chatID string, member tbot.User, settings *captchaSettings) {
+	if err := a.tgClient.RestrictMember(chatID, member.ID, &tbot.ChatPermissions{}, 0); err != nil {
+		log.Error("Error restricting new chat member ", err)
+		return
+	}
+
+	challenge := &captchaChallenge{settings: settings, member: member, answer: "ok", deadline: time.Now().Add(settings.timeout)}
+	data := captchaPrefix + strconv.Itoa(member.ID) + ":"
+
+	var question string
+	var buttons []InlineKeyboardButton
+	if settings.challenge == "math" {
+		var answer int
+		var options []int
+		question, answer, options = mathChallenge(rand.New(rand.NewSource(time.Now().UnixNano())))
+		challenge.answer = strconv.Itoa(answer)
+		for _, option := range options {
+			buttons = append(buttons, InlineKeyboardButton{Text: strconv.Itoa(option), CallbackData: data + strconv.Itoa(option)})
+		}
+	} else {
+		buttons = append(buttons, InlineKeyboardButton{Text: settings.button, CallbackData: data + challenge.answer})
+	}
+
+	key := captchaKey(chatID, member.ID)
+
+	//challenge is registered before it is sent, so that answer arriving right after sending finds it
+	a.armChallenge(chatID, challenge, true)
+
+	keyboard := &InlineKeyboardMarkup{InlineKeyboard: [][]InlineKeyboardButton{buttons}}
+	id, err := a.tgClient.SendText(chatID, captchaText(settings.text, member, settings, question), optReplyMarkup(keyboard))
+	if err != nil {
+		log.Error("Error sending captcha ", err)
+		return
+	}
+
+	a.captchaMu.Lock()
+	pending := a.challenges[key] == challenge
+	if pending {
+		challenge.messageID = id
+	}
+	a.captchaMu.Unlock()
+
+	if !pending {
+		//member left or challenge timed out while it was being sent
+		a.deleteMessage(chatID, id)
+		return
+	}
+	a.storeChallenge(key, challenge)
+}
+
+// armChallenge registers pending challenge and kicks the member once its deadline passes,
+// pending challenge of the member is replaced only if replace is set. Reports whether challenge is registered
+func (a *application) armChallenge(chatID string, challenge *captchaChallenge, replace bool) bool {
+	key := captchaKey(chatID, challenge.member.ID)
+
+	a.captchaMu.Lock()
+	defer a.captchaMu.Unlock()
+
+	if a.challenges == nil {
+		a.challenges = map[string]*captchaChallenge{}
+	}
+	if prev, ok := a.challenges[key]; ok {
+		if !replace {
+			return false
+		}
+		prev.timer.Stop()
+	}
+	challenge.timer = time.AfterFunc(time.Until(challenge.deadline), func() {
+		if a.takeChallenge(chatID, challenge.member.ID, challenge) != nil {
+			a.finishCaptcha(chatID, challenge, false)
+		}
+	})
+	a.challenges[key] = challenge
+
+	return true
+}
+
+// rearmChallenges arms timers of challenges kept in session store before restart, so that members who never answer are kicked,
+// member whose deadline has passed is kicked right away. All stored challenges are re-armed if chat id is empty,
+// challenges of chats with captcha disabled use empty settings
+func (a *application) rearmChallenges(chatID string) {
+	lister, ok := a.cache.(KeyLister)
+	if !ok {
+		return
+	}
+
+	prefix := captchaStoreKey("")
+	if chatID != "" {
+		prefix = captchaStoreKey(chatID + "|")
+	}
+	keys, err := lister.Keys(prefix)
+	if err != nil {
+		log.Error("Error listing stored captchas ", err)
+		return
+	}
+
+	for _, key := range keys {
+		challengeChatID := strings.TrimPrefix(key, captchaStoreKey(""))
+		if i := strings.LastIndex(challengeChatID, "|"); i > 0 {
+			challengeChatID = challengeChatID[:i]
+		} else {
+			continue
+		}
+
+		val, err := a.cache.Get(key)
+		if err != nil {
+			log.Error("Error restoring captcha ", err)
+			continue
+		}
+		data, ok := val.(string)
+		if !ok {
+			continue
+		}
+		stored := storedChallenge{}
+		if err := json.Unmarshal([]byte(data), &stored); err != nil {
+			continue
+		}
+
+		a.captchaMu.Lock()
+		settings := a.captchas[challengeChatID]
+		a.captchaMu.Unlock()
+		if settings == nil {
+			settings = &captchaSettings{}
+		}
+
+		a.armChallenge(challengeChatID, &captchaChallenge{
+			settings:  settings,
+			member:    stored.Member,
+			messageID: stored.MessageID,
+			answer:    stored.Answer,
+			deadline:  time.Unix(stored.Deadline, 0),
+		}, false)
+	}
+}
+
+// storeChallenge keeps pending challenge in session store
+func (a *application) storeChallenge(key string, challenge *captchaChallenge) {
+	if a.cache == nil {
+		return
+	}
+
+	data, _ := json.Marshal(storedChallenge{
+		Member:    challenge.member,
+		MessageID: challenge.messageID,
+		Answer:    challenge.answer,
+		Deadline:  challenge.deadline.Unix(),
+	})
+	if err := a.cache.Set(captchaStoreKey(key), string(data)); err != nil {
+		log.Error("Error storing captcha ", err)
+	}
+}
+
+// restoreChallenge takes challenge sent before restart from session store,
+// challenge which is not stored is returned without answer
+func (a *application) restoreChallenge(chatID string, member tbot.User, messageID int) *captchaChallenge {
+	a.captchaMu.Lock()
+	settings := a.captchas[chatID]
+	a.captchaMu.Unlock()
+
+	if settings == nil {
+		settings = &captchaSettings{}
+	}
+	challenge := &captchaChallenge{settings: settings, member: member, messageID: messageID}
+
+	if a.cache == nil {
+		return challenge
+	}
+	key := captchaStoreKey(captchaKey(chatID, member.ID))
+	val, err := a.cache.Get(key)
+	if err != nil {
+		log.Error("Error restoring captcha ", err)
+	}
+	data, ok := val.(string)
+	if !ok {
+		return challenge
+	}
+	a.cache.Remove(key)
+
+	stored := storedChallenge{}
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return challenge
+	}
+	challenge.member = stored.Member
+	challenge.answer = stored.Answer
+	challenge.deadline = time.Unix(stored.Deadline, 0)
+
+	return challenge
+}
+
+// takeChallenge removes pending challenge of the member and stops its timer, returns nil if there is none.
+// If expected challenge is given, only that challenge is taken
+func (a *application) takeChallenge(chatID string, userID int, expected *captchaChallenge) *captchaChallenge {
+	key := captchaKey(chatID, userID)
+
+	a.captchaMu.Lock()
+	challenge, ok := a.challenges[key]
+	if !ok || (expected != nil && challenge != expected) {
+		a.captchaMu.Unlock()
+		return nil
+	}
+	challenge.timer.Stop()
+	delete(a.challenges, key)
+	a.captchaMu.Unlock()
+
+	if a.cache != nil {
+		a.cache.Remove(captchaStoreKey(key))
+	}
+
+	return challenge
+}
+
+// handleCaptchaAnswer checks answer to challenge, presses of other users are ignored
+func (a *application) handleCaptchaAnswer(cq *tbot.CallbackQuery) {
+	parts := strings.SplitN(strings.TrimPrefix(cq.Data, captchaPrefix), ":", 2)
+	if len(parts) != 2 || cq.Message == nil || parts[0] != strconv.Itoa(cq.From.ID) {
+		return
+	}
+
+	chatID := cq.Message.Chat.ID
+	challenge := a.takeChallenge(chatID, cq.From.ID, nil)
+	if challenge == nil {
+		challenge = a.restoreChallenge(chatID, *cq.From, cq.Message.MessageID)
+	}
+
+	passed := parts[1] == challenge.answer && time.Now().Before(challenge.deadline)
+	if challenge.answer == "" {
+		//challenge of unknown answer, e.g. sent before restart with memory store, is passed so that member is not restricted forever
+		passed = true
+	}
+
+	a.finishCaptcha(chatID, challenge, passed)
+}
+
+// finishCaptcha restores default chat permissions of the member who passed verification or kicks the one who failed,
+// the member may join again
+func (a *application) finishCaptcha(chatID string, challenge *captchaChallenge, passed bool) {
+	if challenge.messageID != 0 {
+		a.deleteMessage(chatID, challenge.messageID)
+	}
+
+	member := challenge.member
+	text := challenge.settings.success
+	if passed {
+		if permissions, err := a.tgClient.DefaultPermissions(chatID); err != nil {
+			log.Error("Error getting default permissions of chat ", err)
+		} else if err := a.tgClient.RestrictMember(chatID, member.ID, permissions, 0); err != nil {
+			log.Error("Error lifting restrictions of verified member ", err)
+		}
+	} else {
+		text = challenge.settings.fail
+		if err := a.tgClient.BanMember(chatID, member.ID, 0); err != nil {
+			log.Error("Error kicking unverified member ", err)
+		} else if err := a.tgClient.UnbanMember(chatID, member.ID); err != nil {
+			log.Error("Error unbanning kicked member ", err)
+		}
+	}
+
+	if text != "" {
+		if _, err := a.tgClient.SendText(chatID, captchaText(text, member, challenge.settings, ""), optNone); err != nil {
+			log.Error("Error sending captcha result ", err)
+		}
+	}
+
+	if !a.hasHandler("onCaptcha") {
+		return
+	}
+	if _, err := a.callHandler(chatID, "onCaptcha", &CaptchaResult{ChatID: chatID, User: member, Passed: passed}); err != nil {
+		log.Error("Error in onCaptcha ", err)
+	}
+}
+
+func (a *application) getEnableCaptchaFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		chatID := chatIDArgument(call.Argument(0), userID)
+		if chatID == "" {
+			log.Error("Error enabling captcha, chat id is missing")
+			return otto.FalseValue()
+		}
+
+		settings, err := captchaSettingsFromObject(objectArgument(call, 1))
+		if err != nil {
+			log.Error("Error enabling captcha ", err)
+			return otto.FalseValue()
+		}
+		a.enableCaptcha(chatID, settings)
+
+		return otto.TrueValue()
+	}
+}
+
+func (a *application) getDisableCaptchaFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		a.disableCaptcha(chatIDArgument(call.Argument(0), userID))
+
+		return otto.Value{}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yanzay/tbot/v2"
+)
+
+const joinUpdate = `{"update_id":1,"chat_member":{"chat":{"id":-100123,"type":"supergroup"},"from":{"id":42},"date":1,
+	"old_chat_member":{"user":{"id":42,"first_name":"John"},"status":"left"},
+	"new_chat_member":{"user":{"id":42,"first_name":"John"},"status":"member"}}}`
+
+func TestCaptchaPassed(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	vm := VmFactoryImpl{}.GetVm()
+	a := &application{tgClient: telebot, vmTemplate: vm, cache: newMemoryStore(time.Hour)}
+	vm.Set("enableCaptcha", a.getEnableCaptchaFunc(""))
+	vm.Set("send", a.getSendFunc(""))
+	vm.Run(`enableCaptcha("-100123", {text: "Hi {name}, you have {seconds} seconds", button: "Human", success: "Welcome, {name}"})
+	bot = {
+		onCaptcha: function (result) { send(result.User.FirstName + " passed: " + result.Passed) },
+		onCallback: function (callback) { send("unexpected callback") }
+	}`)
+
+	var markup url.Values
+	telebot.On("RestrictMember", "-100123", 42, &tbot.ChatPermissions{}, int64(0)).Return(nil)
+	telebot.On("SendText", "-100123", "Hi John, you have 120 seconds", mock.AnythingOfType("func(url.Values)")).Return(5, nil).Run(func(args mock.Arguments) {
+		markup = url.Values{}
+		args.Get(2).(func(url.Values))(markup)
+	})
+	telebot.On("AnswerCallback", mock.Anything).Return(nil)
+	defaults := &tbot.ChatPermissions{CanSendMessages: true, CanSendPolls: true}
+	telebot.On("DefaultPermissions", "-100123").Return(defaults, nil)
+	telebot.On("RestrictMember", "-100123", 42, defaults, int64(0)).Return(nil)
+	telebot.On("DeleteMsg", "-100123", 5).Return(nil)
+	var resultOptions url.Values
+	telebot.On("SendText", "-100123", "Welcome, John", mock.AnythingOfType("func(url.Values)")).Return(6, nil).Run(func(args mock.Arguments) {
+		resultOptions = url.Values{}
+		args.Get(2).(func(url.Values))(resultOptions)
+	})
+	telebot.On("SendText", "-100123", "John passed: true", mock.AnythingOfType("func(url.Values)")).Return(7, nil)
+
+	update := &Update{}
+	json.Unmarshal([]byte(joinUpdate), update)
+	a.handleUpdate(update)
+
+	assert.Equal(t, `{"inline_keyboard":[[{"text":"Human","callback_data":"captcha:42:ok"}]]}`, markup.Get("reply_markup"))
+	stored, _ := a.cache.Get("captcha|-100123|42")
+	assert.Contains(t, stored, `"message_id":5`)
+
+	message := &tbot.Message{MessageID: 5, Chat: tbot.Chat{ID: "-100123"}}
+	a.handleUpdate(&Update{Update: tbot.Update{CallbackQuery: &tbot.CallbackQuery{ID: "1", From: &tbot.User{ID: 43}, Message: message, Data: "captcha:42:ok"}}})
+	a.handleUpdate(&Update{Update: tbot.Update{CallbackQuery: &tbot.CallbackQuery{ID: "2", From: &tbot.User{ID: 42}, Message: message, Data: "captcha:42:ok"}}})
+
+	telebot.AssertExpectations(t)
+	telebot.AssertNumberOfCalls(t, "RestrictMember", 2)
+	assert.Empty(t, a.challenges)
+	assert.Empty(t, resultOptions)
+	stored, _ = a.cache.Get("captcha|-100123|42")
+	assert.Nil(t, stored)
+}
+
+func TestCaptchaAnsweredWhileSending(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	vm := VmFactoryImpl{}.GetVm()
+	a := &application{tgClient: telebot, vmTemplate: vm}
+	a.enableCaptcha("-100123", &captchaSettings{challenge: "button", timeout: time.Minute, text: "Hi", button: "Human"})
+
+	message := &tbot.Message{MessageID: 5, Chat: tbot.Chat{ID: "-100123"}}
+	telebot.On("RestrictMember", "-100123", 42, &tbot.ChatPermissions{}, int64(0)).Return(nil)
+	telebot.On("AnswerCallback", mock.Anything).Return(nil)
+	telebot.On("DefaultPermissions", "-100123").Return(&tbot.ChatPermissions{CanSendMessages: true}, nil)
+	telebot.On("RestrictMember", "-100123", 42, &tbot.ChatPermissions{CanSendMessages: true}, int64(0)).Return(nil)
+	telebot.On("DeleteMsg", "-100123", 5).Return(nil)
+	//member answers before sending returns
+	telebot.On("SendText", "-100123", "Hi", mock.AnythingOfType("func(url.Values)")).Return(5, nil).Run(func(args mock.Arguments) {
+		a.handleUpdate(&Update{Update: tbot.Update{CallbackQuery: &tbot.CallbackQuery{ID: "1", From: &tbot.User{ID: 42}, Message: message, Data: "captcha:42:ok"}}})
+	})
+
+	update := &Update{}
+	json.Unmarshal([]byte(joinUpdate), update)
+	a.handleUpdate(update)
+
+	telebot.AssertExpectations(t)
+	assert.Empty(t, a.challenges)
+}
+
+func TestCaptchaAfterRestart(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	vm := VmFactoryImpl{}.GetVm()
+	a := &application{tgClient: telebot, vmTemplate: vm, cache: newMemoryStore(time.Hour)}
+
+	deadline := time.Now().Add(time.Minute).Unix()
+	a.cache.Set("captcha|-100123|42", fmt.Sprintf(`{"member":{"id":42,"first_name":"John"},"message_id":5,"answer":"7","deadline":%d}`, deadline))
+
+	message := &tbot.Message{MessageID: 5, Chat: tbot.Chat{ID: "-100123"}}
+	telebot.On("AnswerCallback", mock.Anything).Return(nil)
+	telebot.On("DeleteMsg", "-100123", 5).Return(nil)
+	telebot.On("BanMember", "-100123", 42, int64(0)).Return(nil)
+	telebot.On("UnbanMember", "-100123", 42).Return(nil)
+
+	//stored challenge is checked
+	a.handleUpdate(&Update{Update: tbot.Update{CallbackQuery: &tbot.CallbackQuery{ID: "1", From: &tbot.User{ID: 42}, Message: message, Data: "captcha:42:8"}}})
+
+	telebot.AssertCalled(t, "BanMember", "-100123", 42, int64(0))
+
+	//challenge which is not stored lifts restrictions
+	telebot.On("DefaultPermissions", "-100123").Return(&tbot.ChatPermissions{CanSendMessages: true}, nil)
+	telebot.On("RestrictMember", "-100123", 43, &tbot.ChatPermissions{CanSendMessages: true}, int64(0)).Return(nil)
+	telebot.On("DeleteMsg", "-100123", 6).Return(nil)
+
+	message = &tbot.Message{MessageID: 6, Chat: tbot.Chat{ID: "-100123"}}
+	a.handleUpdate(&Update{Update: tbot.Update{CallbackQuery: &tbot.CallbackQuery{ID: "2", From: &tbot.User{ID: 43}, Message: message, Data: "captcha:43:3"}}})
+
+	telebot.AssertExpectations(t)
+}
+
+func TestCaptchaRearmedAfterRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "captcha_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := newFileStore(filepath.Join(dir, "sessions.json"), time.Hour)
+	assert.Nil(t, err)
+
+	telebot := &mocks.Telebot{}
+	vm := VmFactoryImpl{}.GetVm()
+	a := &application{tgClient: telebot, vmTemplate: vm, cache: store}
+
+	store.Set("captcha|-100123|42", fmt.Sprintf(`{"member":{"id":42,"first_name":"John"},"message_id":5,"answer":"ok","deadline":%d}`,
+		time.Now().Add(-time.Minute).Unix()))
+	store.Set("captcha|-100456|43", fmt.Sprintf(`{"member":{"id":43,"first_name":"Jane"},"message_id":6,"answer":"ok","deadline":%d}`,
+		time.Now().Add(time.Hour).Unix()))
+
+	telebot.On("DeleteMsg", "-100123", 5).Return(nil)
+	telebot.On("BanMember", "-100123", 42, int64(0)).Return(nil)
+	telebot.On("UnbanMember", "-100123", 42).Return(nil)
+	telebot.On("SendText", "-100123", "John failed", mock.AnythingOfType("func(url.Values)")).Return(7, nil)
+
+	//member whose deadline passed while bot was down is kicked once captcha is enabled
+	a.enableCaptcha("-100123", &captchaSettings{challenge: "button", timeout: time.Minute, fail: "{name} failed"})
+
+	time.Sleep(50 * time.Millisecond)
+
+	telebot.AssertExpectations(t)
+	val, _ := store.Get("captcha|-100123|42")
+	assert.Nil(t, val)
+
+	//challenges of other chats are re-armed after onInit
+	a.rearmChallenges("")
+
+	a.captchaMu.Lock()
+	challenge := a.challenges["-100456|43"]
+	a.captchaMu.Unlock()
+
+	assert.NotNil(t, challenge)
+	assert.Equal(t, 6, challenge.messageID)
+	challenge.timer.Stop()
+}
+
+func TestCaptchaTimeout(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	vm := VmFactoryImpl{}.GetVm()
+	a := &application{tgClient: telebot, vmTemplate: vm}
+	a.enableCaptcha("-100123", &captchaSettings{challenge: "math", timeout: 50 * time.Millisecond, text: defaultCaptchaMathText, fail: "{name} failed"})
+
+	telebot.On("RestrictMember", "-100123", 42, &tbot.ChatPermissions{}, int64(0)).Return(nil)
+	telebot.On("SendText", "-100123", mock.MatchedBy(func(text string) bool { return strings.HasPrefix(text, "John, solve ") }), mock.AnythingOfType("func(url.Values)")).Return(5, nil)
+	telebot.On("DeleteMsg", "-100123", 5).Return(nil)
+	telebot.On("BanMember", "-100123", 42, int64(0)).Return(nil)
+	telebot.On("UnbanMember", "-100123", 42).Return(nil)
+	telebot.On("SendText", "-100123", "John failed", mock.AnythingOfType("func(url.Values)")).Return(6, nil)
+
+	update := &Update{}
+	json.Unmarshal([]byte(joinUpdate), update)
+	a.handleUpdate(update)
+
+	time.Sleep(150 * time.Millisecond)
+
+	telebot.AssertExpectations(t)
+	assert.Empty(t, a.challenges)
+}
+
+func TestMathChallenge(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		question, answer, options := mathChallenge(rnd)
+
+		assert.Regexp(t, `^\d \+ \d$`, question)
+		assert.Len(t, options, 4)
+		assert.Contains(t, options, answer)
+		for _, option := range options {
+			assert.True(t, option > 0)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	valid := []string{"* * * * *", "0 9 * * MON", "*/5 1-3,7 1 JAN-MAR sun", "@daily", "@every 10s", "CRON_TZ=UTC 0 0 * * *", "5/15 * * * 7"}
+	for _, spec := range valid {
+		_, err := parseCron(spec, time.UTC)
+		assert.Nil(t, err, spec)
+	}
+
+	invalid := []string{"", "* * * *", "60 * * * *", "* * * * MOON", "5-1 * * * *", "*/0 * * * *", "@every -1s", "CRON_TZ=Nowhere/City * * * * *"}
+	for _, spec := range invalid {
+		_, err := parseCron(spec, time.UTC)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	//friday
+	from := time.Date(2020, time.May, 1, 10, 30, 15, 0, time.UTC)
+
+	testCases := map[string]time.Time{
+		"* * * * *":     time.Date(2020, time.May, 1, 10, 31, 0, 0, time.UTC),
+		"0 9 * * MON":   time.Date(2020, time.May, 4, 9, 0, 0, 0, time.UTC),
+		"*/20 * * * *":  time.Date(2020, time.May, 1, 10, 40, 0, 0, time.UTC),
+		"0 0 1 * *":     time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 29 FEB *":  time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"0 12 15 * FRI": time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC),
+		"0 0 * * 7":     time.Date(2020, time.May, 3, 0, 0, 0, 0, time.UTC),
+		"@every 90s":    from.Add(90 * time.Second),
+		"30 10 1 5 *":   time.Date(2021, time.May, 1, 10, 30, 0, 0, time.UTC),
+	}
+
+	for spec, expected := range testCases {
+		schedule, err := parseCron(spec, time.UTC)
+		assert.Nil(t, err)
+		assert.True(t, expected.Equal(schedule.Next(from)), "%s: expected %s got %s", spec, expected, schedule.Next(from))
+	}
+
+	//april has no 31st day
+	schedule, _ := parseCron("0 0 31 4 *", time.UTC)
+	assert.True(t, schedule.Next(from).IsZero())
+
+	//time zone
+	location, _ := time.LoadLocation("Asia/Bishkek")
+	schedule, _ = parseCron("CRON_TZ=Asia/Bishkek 0 9 * * *", time.UTC)
+	assert.True(t, time.Date(2020, time.May, 2, 9, 0, 0, 0, location).Equal(schedule.Next(from)))
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// column types of postgres and mysql by database type name, names of mysql unsigned types are prefixed with UNSIGNED
+var (
+	integerColumns = map[string]bool{
+		"INT2": true, "INT4": true, "INT8": true, "OID": true,
+		"TINYINT": true, "SMALLINT": true, "MEDIUMINT": true, "INT": true, "INTEGER": true, "BIGINT": true, "YEAR": true,
+	}
+	decimalColumns = map[string]bool{
+		"FLOAT4": true, "FLOAT8": true, "NUMERIC": true,
+		"FLOAT": true, "DOUBLE": true, "REAL": true, "DECIMAL": true,
+	}
+	jsonColumns = map[string]bool{
+		"JSON": true, "JSONB": true,
+	}
+	binaryColumns = map[string]bool{
+		"BYTEA":  true,
+		"BINARY": true, "VARBINARY": true, "TINYBLOB": true, "BLOB": true, "MEDIUMBLOB": true, "LONGBLOB": true,
+	}
+	dateColumns = map[string]bool{
+		"DATE": true,
+	}
+	timestampColumns = map[string]bool{
+		"TIMESTAMP": true, "TIMESTAMPTZ": true, "DATETIME": true,
+	}
+)
+
+// mysql returns timestamps as text unless parseTime is set in connection string,
+// they are read in time zone set by loc parameter of connection string (UTC by default)
+const mysqlTimestampLayout = "2006-01-02 15:04:05.999999"
+
+// maxSafeInteger is the largest integer js number keeps exactly, larger integers are returned as strings
+const maxSafeInteger = 1<<53 - 1
+
+// dbLocation returns time zone of timestamps returned as text, it is loc parameter of mysql connection string
+func dbLocation(driver string, connStr string) *time.Location {
+	if driver != "mysql" {
+		return time.UTC
+	}
+
+	cfg, err := mysql.ParseDSN(connStr)
+	if err != nil || cfg.Loc == nil {
+		return time.UTC
+	}
+
+	return cfg.Loc
+}
+
+func safeInteger(number int64) bool {
+	return number >= -maxSafeInteger && number <= maxSafeInteger
+}
+
+// columnValue converts value scanned from column to value encoded to json: numbers, parsed json, ISO timestamps,
+// base64 encoded bytes (encoded by json.Marshal) or strings. Integers js can not keep exactly are returned as strings,
+// timestamps returned as text are read in time zone loc. NULL is returned as nil
+func columnValue(columnType string, value interface{}, loc *time.Location) interface{} {
+	columnType = strings.TrimPrefix(strings.ToUpper(columnType), "UNSIGNED ")
+
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		if dateColumns[columnType] {
+			return v.Format("2006-01-02")
+		}
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return bytesColumnValue(columnType, v, loc)
+	case string:
+		return bytesColumnValue(columnType, []byte(v), loc)
+	case int64:
+		if !safeInteger(v) {
+			return strconv.FormatInt(v, 10)
+		}
+		return v
+	case uint64:
+		if v > maxSafeInteger {
+			return strconv.FormatUint(v, 10)
+		}
+		return v
+	case float64:
+		//NaN and infinity have no json representation
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		return v
+	default:
+		//numbers and booleans decoded by driver
+		return v
+	}
+}
+
+func bytesColumnValue(columnType string, data []byte, loc *time.Location) interface{} {
+	text := string(data)
+
+	switch {
+	case integerColumns[columnType]:
+		if number, err := strconv.ParseInt(text, 10, 64); err == nil && safeInteger(number) {
+			return number
+		}
+	case decimalColumns[columnType]:
+		//json.Number keeps digits of decimals as is
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			return json.Number(text)
+		}
+	case jsonColumns[columnType]:
+		if json.Valid(data) {
+			return json.RawMessage(text)
+		}
+	case binaryColumns[columnType]:
+		return append([]byte{}, data...)
+	case timestampColumns[columnType]:
+		if loc == nil {
+			loc = time.UTC
+		}
+		if t, err := time.ParseInLocation(mysqlTimestampLayout, text, loc); err == nil {
+			return t.Format(time.RFC3339Nano)
+		}
+	}
+
+	return text
+}
+
+// csvValue formats column value for report, json and binary values are formatted as in dbQuery result
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case json.RawMessage:
+		return string(v)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnValue(t *testing.T) {
+	timestamp := time.Date(2020, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		columnType string
+		value      interface{}
+		expected   interface{}
+	}{
+		{"INT8", nil, nil},
+		{"INT8", int64(9007199254740), int64(9007199254740)},
+		{"INT8", int64(9007199254740993), "9007199254740993"},
+		{"INT8", int64(-9007199254740993), "-9007199254740993"},
+		{"INT8", uint64(9007199254740991), uint64(9007199254740991)},
+		{"BIGINT", []byte("42"), int64(42)},
+		{"BIGINT", []byte("-9007199254740992"), "-9007199254740992"},
+		{"UNSIGNED BIGINT", []byte("18446744073709551615"), "18446744073709551615"},
+		{"NUMERIC", []byte("12.50"), json.Number("12.50")},
+		{"DECIMAL", []byte("-0.1"), json.Number("-0.1")},
+		{"FLOAT8", 1.5, 1.5},
+		{"FLOAT8", math.NaN(), "NaN"},
+		{"BOOL", true, true},
+		{"JSONB", []byte(`{"b":1,"a":[1,2]}`), json.RawMessage(`{"b":1,"a":[1,2]}`)},
+		{"JSON", []byte(`not json`), "not json"},
+		{"BYTEA", []byte{0, 1, 2}, []byte{0, 1, 2}},
+		{"BLOB", []byte("data"), []byte("data")},
+		{"TIMESTAMPTZ", timestamp, "2020-05-01T10:30:00Z"},
+		{"DATE", timestamp, "2020-05-01"},
+		{"DATETIME", []byte("2020-05-01 10:30:00"), "2020-05-01T10:30:00Z"},
+		{"DATE", []byte("2020-05-01"), "2020-05-01"},
+		{"VARCHAR", []byte("text"), "text"},
+		{"UUID", []byte("5f0c2a5e-6e0c-4a8b-9d7a-1f3b2c4d5e6f"), "5f0c2a5e-6e0c-4a8b-9d7a-1f3b2c4d5e6f"},
+		{"", "text", "text"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, columnValue(test.columnType, test.value, time.UTC), test.columnType)
+	}
+
+	//mysql timestamps returned as text are in time zone of connection
+	loc := dbLocation("mysql", "user:password@/dbname?loc=Asia%2FBishkek")
+	assert.Equal(t, "Asia/Bishkek", loc.String())
+	assert.Equal(t, "2020-05-01T10:30:00+06:00", columnValue("DATETIME", []byte("2020-05-01 10:30:00"), loc))
+	assert.Equal(t, time.UTC, dbLocation("mysql", "user:password@/dbname"))
+	assert.Equal(t, time.UTC, dbLocation("postgres", "host=localhost"))
+}
+
+func TestQueryDBTypes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "name", "score", "active"}).
+		AddRow(int64(1), "tom", 1.5, true).
+		AddRow(int64(2), nil, nil, nil)
+	mock.ExpectQuery("select").WillReturnRows(rows)
+
+	a := &application{dbClient: db}
+	vm := otto.New()
+	vm.Set("dbQuery", a.getQueryDBFunc())
+
+	val, err := vm.Run(`dbQuery("select id, name, score, active from players")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"id":1,"name":"tom","score":1.5,"active":true},{"id":2,"name":null,"score":null,"active":null}]`, val.String())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCSVValue(t *testing.T) {
+	assert.Equal(t, "", csvValue(nil))
+	assert.Equal(t, "12.50", csvValue(json.Number("12.50")))
+	assert.Equal(t, `{"a":1}`, csvValue(json.RawMessage(`{"a":1}`)))
+	assert.Equal(t, "AQI=", csvValue([]byte{1, 2}))
+	assert.Equal(t, "42", csvValue(int64(42)))
+}
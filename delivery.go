@@ -0,0 +1,440 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+)
+
+// queuedMessage is a message to be sent at the specified time
+type queuedMessage struct {
+	ID         string      `json:"id"`
+	ChatID     string      `json:"chat_id"`
+	Text       string      `json:"text"`
+	Options    interface{} `json:"options,omitempty"`
+	Attachment string      `json:"attachment,omitempty"`
+	At         time.Time   `json:"at"`
+	Attempts   int         `json:"attempts,omitempty"`
+}
+
+// failed deliveries are retried with growing delay and dropped after max attempts,
+// message being sent is leased so that it is not sent by another instance sharing the queue
+const (
+	maxDeliveryAttempts = 5
+	deliveryRetryDelay  = time.Minute
+	deliveryLease       = 5 * time.Minute
+)
+
+// MessageQueue keeps messages scheduled for delivery, queue must survive restarts.
+// Claim atomically postpones due message until the specified time and reports whether caller won the message,
+// Update replaces message and its delivery time
+type MessageQueue interface {
+	Add(msg queuedMessage) error
+	Remove(id string) (bool, error)
+	Due(now time.Time) ([]queuedMessage, error)
+	Claim(id string, now time.Time, until time.Time) (bool, error)
+	Update(msg queuedMessage) error
+}
+
+func newMessageQueue(kind string, db *sql.DB) (MessageQueue, error) {
+	switch kind {
+	case "", "file":
+		return newFileQueue(GetEnv("QUEUE_FILE", "queue.json"))
+	case "sql":
+		if db == nil {
+			return nil, fmt.Errorf("Message queue %s requires db connection", kind)
+		}
+		return newSQLQueue(db, GetEnv("DB_DRIVER", ""), GetEnv("QUEUE_TABLE", "bot_queue"))
+	default:
+		return nil, fmt.Errorf("Unknown message queue %s", kind)
+	}
+}
+
+type fileQueue struct {
+	mu       sync.Mutex
+	path     string
+	messages map[string]queuedMessage
+}
+
+func newFileQueue(path string) (*fileQueue, error) {
+	q := &fileQueue{path: path, messages: map[string]queuedMessage{}}
+
+	if FileExists(path) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &q.messages); err != nil {
+				return nil, fmt.Errorf("Error parsing queue file %s: %v", path, err)
+			}
+		}
+	}
+
+	return q, nil
+}
+
+func (q *fileQueue) Add(msg queuedMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.messages[msg.ID] = msg
+
+	return q.flush()
+}
+
+func (q *fileQueue) Remove(id string) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.messages[id]; !ok {
+		return false, nil
+	}
+	delete(q.messages, id)
+
+	return true, q.flush()
+}
+
+func (q *fileQueue) Claim(id string, now time.Time, until time.Time) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	msg, ok := q.messages[id]
+	if !ok || msg.At.After(now) {
+		return false, nil
+	}
+	msg.At = until
+	q.messages[id] = msg
+
+	return true, q.flush()
+}
+
+func (q *fileQueue) Update(msg queuedMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.messages[msg.ID]; !ok {
+		return nil
+	}
+	q.messages[msg.ID] = msg
+
+	return q.flush()
+}
+
+func (q *fileQueue) Due(now time.Time) ([]queuedMessage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var due []queuedMessage
+	for _, msg := range q.messages {
+		if !msg.At.After(now) {
+			due = append(due, msg)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].At.Before(due[j].At)
+	})
+
+	return due, nil
+}
+
+// flush must be called under lock
+func (q *fileQueue) flush() error {
+	data, err := json.Marshal(q.messages)
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(q.path, data)
+}
+
+type sqlQueue struct {
+	db     *sql.DB
+	driver string
+	table  string
+}
+
+func newSQLQueue(db *sql.DB, driver string, table string) (*sqlQueue, error) {
+	q := &sqlQueue{db: db, driver: driver, table: table}
+
+	_, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id VARCHAR(64) PRIMARY KEY, message TEXT, deliver_at BIGINT)", table))
+	if err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+func (q *sqlQueue) Add(msg queuedMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.db.Exec(fmt.Sprintf("INSERT INTO %s (id, message, deliver_at) VALUES (%s, %s, %s)",
+		q.table, bindVar(q.driver, 1), bindVar(q.driver, 2), bindVar(q.driver, 3)), msg.ID, string(data), msg.At.Unix())
+
+	return err
+}
+
+func (q *sqlQueue) Remove(id string) (bool, error) {
+	res, err := q.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = %s", q.table, bindVar(q.driver, 1)), id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+
+	return affected > 0, err
+}
+
+func (q *sqlQueue) Claim(id string, now time.Time, until time.Time) (bool, error) {
+	res, err := q.db.Exec(fmt.Sprintf("UPDATE %s SET deliver_at = %s WHERE id = %s AND deliver_at <= %s",
+		q.table, bindVar(q.driver, 1), bindVar(q.driver, 2), bindVar(q.driver, 3)), until.Unix(), id, now.Unix())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+
+	return affected > 0, err
+}
+
+func (q *sqlQueue) Update(msg queuedMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.db.Exec(fmt.Sprintf("UPDATE %s SET message = %s, deliver_at = %s WHERE id = %s",
+		q.table, bindVar(q.driver, 1), bindVar(q.driver, 2), bindVar(q.driver, 3)), string(data), msg.At.Unix(), msg.ID)
+
+	return err
+}
+
+func (q *sqlQueue) Due(now time.Time) ([]queuedMessage, error) {
+	rows, err := q.db.Query(fmt.Sprintf("SELECT message FROM %s WHERE deliver_at <= %s ORDER BY deliver_at", q.table, bindVar(q.driver, 1)), now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []queuedMessage
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var msg queuedMessage
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			log.Error("Error parsing queued message ", err)
+			continue
+		}
+		due = append(due, msg)
+	}
+
+	return due, rows.Err()
+}
+
+// enqueueMessage schedules message delivery and returns job id, empty id means failure
+func (a *application) enqueueMessage(msg queuedMessage) string {
+	if a.queue == nil {
+		log.Error("Error scheduling message, message queue is not configured")
+		return ""
+	}
+	msg.ID = RandomID()
+	if err := a.queue.Add(msg); err != nil {
+		log.Error("Error scheduling message ", err)
+		return ""
+	}
+
+	return msg.ID
+}
+
+func (a *application) cancelMessage(id string) bool {
+	if a.queue == nil {
+		return false
+	}
+
+	removed, err := a.queue.Remove(id)
+	if err != nil {
+		log.Error("Error cancelling scheduled message ", err)
+	}
+
+	return removed
+}
+
+// deliverMessages sends due messages. Each message is claimed before sending, so that it is sent once
+// even if several instances share the queue, and removed after it is sent. Message failed to send is rescheduled
+// until max attempts are exceeded. Message sent but not removed is never sent again, its removal is retried
+func (a *application) deliverMessages(now time.Time) {
+	due, err := a.queue.Due(now)
+	if err != nil {
+		log.Error("Error reading message queue ", err)
+		return
+	}
+
+	for _, msg := range due {
+		claimed, err := a.queue.Claim(msg.ID, now, now.Add(deliveryLease))
+		if err != nil {
+			log.Error("Error claiming queued message ", err)
+			continue
+		}
+		if !claimed {
+			//sent by another instance or cancelled
+			continue
+		}
+
+		if _, sent := a.deliveredMessages.Load(msg.ID); !sent {
+			options, inlineOptions := parseSendOptions(msg.Options)
+			if a.sendMessage(msg.ChatID, msg.Text, options, inlineOptions, msg.Attachment) == 0 {
+				a.rescheduleMessage(msg, now)
+				continue
+			}
+		}
+
+		if _, err := a.queue.Remove(msg.ID); err != nil {
+			log.Error("Error removing sent message from queue ", err)
+			a.deliveredMessages.Store(msg.ID, true)
+			continue
+		}
+		a.deliveredMessages.Delete(msg.ID)
+	}
+}
+
+// rescheduleMessage postpones delivery of message failed to send or drops it after max attempts
+func (a *application) rescheduleMessage(msg queuedMessage, now time.Time) {
+	msg.Attempts++
+	if msg.Attempts >= maxDeliveryAttempts {
+		log.Error("Error delivering scheduled message, dropped after attempts ", msg.Attempts)
+		if _, err := a.queue.Remove(msg.ID); err != nil {
+			log.Error("Error removing message from queue ", err)
+		}
+		return
+	}
+
+	msg.At = now.Add(time.Duration(msg.Attempts) * deliveryRetryDelay)
+	if err := a.queue.Update(msg); err != nil {
+		log.Error("Error rescheduling message ", err)
+	}
+}
+
+func (a *application) startDelivery(interval time.Duration) {
+	if a.queue == nil {
+		return
+	}
+
+	go func() {
+		for now := range time.Tick(interval) {
+			a.deliverMessages(now)
+		}
+	}()
+}
+
+// parseTime converts Date, unix time in milliseconds or RFC3339 string to time
+func parseTime(val otto.Value) (time.Time, error) {
+	switch {
+	case val.Class() == "Date":
+		ms, err := val.Object().Call("getTime")
+		if err != nil {
+			return time.Time{}, err
+		}
+		n, err := ms.ToInteger()
+		return time.Unix(0, n*int64(time.Millisecond)), err
+	case val.IsNumber():
+		n, err := val.ToInteger()
+		return time.Unix(0, n*int64(time.Millisecond)), err
+	case val.IsString():
+		return time.Parse(time.RFC3339, val.String())
+	default:
+		return time.Time{}, fmt.Errorf("Unsupported time %v", val)
+	}
+}
+
+// parseDelay converts milliseconds or duration string like "1h30m" to duration
+func parseDelay(val otto.Value) (time.Duration, error) {
+	if val.IsNumber() {
+		ms, err := val.ToInteger()
+		return time.Duration(ms) * time.Millisecond, err
+	}
+
+	return time.ParseDuration(val.String())
+}
+
+// queuedMessageFromCall reads chatID, text, options and attachment arguments starting at index first
+func queuedMessageFromCall(call otto.FunctionCall, first int, userID string) queuedMessage {
+	msg := queuedMessage{ChatID: chatIDArgument(call.Argument(first), userID)}
+
+	if call.Argument(first + 1).IsString() {
+		msg.Text = call.Argument(first + 1).String()
+	}
+	if optionsInterface, err := exportJSON(call.Otto, call.Argument(first+2)); err == nil {
+		msg.Options = optionsInterface
+	}
+	if call.Argument(first + 3).IsString() {
+		msg.Attachment = call.Argument(first + 3).String()
+	}
+
+	return msg
+}
+
+func (a *application) getSendAtFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		at, err := parseTime(call.Argument(0))
+		if err != nil {
+			log.Error("Error scheduling message ", err)
+			return otto.Value{}
+		}
+
+		msg := queuedMessageFromCall(call, 1, userID)
+		if msg.ChatID == "" {
+			log.Error("Error scheduling message, chat id is missing")
+			return otto.Value{}
+		}
+		msg.At = at
+
+		result, _ := otto.ToValue(a.enqueueMessage(msg))
+
+		return result
+	}
+}
+
+func (a *application) getSendAfterFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		delay, err := parseDelay(call.Argument(0))
+		if err != nil {
+			log.Error("Error scheduling message ", err)
+			return otto.Value{}
+		}
+
+		msg := queuedMessageFromCall(call, 1, userID)
+		if msg.ChatID == "" {
+			log.Error("Error scheduling message, chat id is missing")
+			return otto.Value{}
+		}
+		msg.At = time.Now().Add(delay)
+
+		result, _ := otto.ToValue(a.enqueueMessage(msg))
+
+		return result
+	}
+}
+
+func (a *application) getCancelSendFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		result, _ := otto.ToValue(false)
+
+		if call.Argument(0).IsString() {
+			result, _ = otto.ToValue(a.cancelMessage(call.Argument(0).String()))
+		}
+
+		return result
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFileQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "queue.json")
+	now := time.Now()
+
+	queue, err := newFileQueue(path)
+	assert.Nil(t, err)
+
+	assert.Nil(t, queue.Add(queuedMessage{ID: "later", ChatID: userID, Text: "later", At: now.Add(time.Hour)}))
+	assert.Nil(t, queue.Add(queuedMessage{ID: "second", ChatID: userID, Text: "second", At: now.Add(-time.Second)}))
+	assert.Nil(t, queue.Add(queuedMessage{ID: "first", ChatID: userID, Text: "first", At: now.Add(-time.Minute)}))
+
+	//survives restart
+	queue, err = newFileQueue(path)
+	assert.Nil(t, err)
+
+	due, err := queue.Due(now)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(due))
+	assert.Equal(t, "first", due[0].ID)
+	assert.Equal(t, "second", due[1].ID)
+
+	removed, err := queue.Remove("later")
+	assert.Nil(t, err)
+	assert.True(t, removed)
+
+	removed, err = queue.Remove("later")
+	assert.Nil(t, err)
+	assert.False(t, removed)
+
+	//only one claim of due message wins
+	claimed, err := queue.Claim("first", now, now.Add(deliveryLease))
+	assert.Nil(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = queue.Claim("first", now, now.Add(deliveryLease))
+	assert.Nil(t, err)
+	assert.False(t, claimed)
+
+	due, err = queue.Due(now)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(due))
+	assert.Equal(t, "second", due[0].ID)
+}
+
+func TestSQLQueue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS queue").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	queue, err := newSQLQueue(db, "postgres", "queue")
+	assert.Nil(t, err)
+
+	at := time.Unix(1588334400, 0)
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO queue (id, message, deliver_at) VALUES ($1, $2, $3)")).
+		WithArgs("id", sqlmock.AnyArg(), at.Unix()).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	assert.Nil(t, queue.Add(queuedMessage{ID: "id", ChatID: userID, Text: text, At: at}))
+
+	mock.ExpectQuery("SELECT message FROM queue").WithArgs(at.Unix()).
+		WillReturnRows(sqlmock.NewRows([]string{"message"}).AddRow(`{"id":"id","chat_id":"123","text":"hello"}`))
+
+	due, err := queue.Due(at)
+	assert.Nil(t, err)
+	assert.Equal(t, []queuedMessage{{ID: "id", ChatID: "123", Text: "hello"}}, due)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE queue SET deliver_at = $1 WHERE id = $2 AND deliver_at <= $3")).
+		WithArgs(at.Add(deliveryLease).Unix(), "id", at.Unix()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE queue SET deliver_at = $1 WHERE id = $2 AND deliver_at <= $3")).
+		WithArgs(at.Add(deliveryLease).Unix(), "id", at.Unix()).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	claimed, err := queue.Claim("id", at, at.Add(deliveryLease))
+	assert.Nil(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = queue.Claim("id", at, at.Add(deliveryLease))
+	assert.Nil(t, err)
+	assert.False(t, claimed)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE queue SET message = $1, deliver_at = $2 WHERE id = $3")).
+		WithArgs(sqlmock.AnyArg(), at.Add(deliveryRetryDelay).Unix(), "id").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.Nil(t, queue.Update(queuedMessage{ID: "id", ChatID: userID, Text: text, At: at.Add(deliveryRetryDelay), Attempts: 1}))
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM queue WHERE id = $1")).WithArgs("id").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	removed, err := queue.Remove("id")
+	assert.Nil(t, err)
+	assert.True(t, removed)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDeliverMessages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	queue, err := newFileQueue(filepath.Join(dir, "queue.json"))
+	assert.Nil(t, err)
+
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, queue: queue}
+
+	now := time.Now()
+	a.enqueueMessage(queuedMessage{ChatID: userID, Text: text, At: now.Add(-time.Second)})
+	id := a.enqueueMessage(queuedMessage{ChatID: userID, Text: "cancelled", At: now.Add(-time.Second)})
+	a.enqueueMessage(queuedMessage{ChatID: userID, Text: "later", At: now.Add(time.Hour)})
+
+	assert.True(t, a.cancelMessage(id))
+
+	telebot.On("SendText", userID, text, mock.AnythingOfType("func(url.Values)")).Return(1, nil)
+
+	a.deliverMessages(now)
+	//delivered messages are removed
+	a.deliverMessages(now)
+
+	telebot.AssertExpectations(t)
+	telebot.AssertNumberOfCalls(t, "SendText", 1)
+	assert.Equal(t, 1, len(queue.messages))
+}
+
+func TestDeliverMessagesFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	queue, err := newFileQueue(filepath.Join(dir, "queue.json"))
+	assert.Nil(t, err)
+
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, queue: queue}
+
+	now := time.Now()
+	id := a.enqueueMessage(queuedMessage{ChatID: userID, Text: text, At: now.Add(-time.Second)})
+
+	telebot.On("SendText", userID, text, mock.AnythingOfType("func(url.Values)")).Return(0, errors.New("Too Many Requests")).Once()
+
+	//failed message is kept and rescheduled
+	a.deliverMessages(now)
+
+	assert.Equal(t, 1, queue.messages[id].Attempts)
+	assert.Equal(t, now.Add(deliveryRetryDelay).Unix(), queue.messages[id].At.Unix())
+
+	a.deliverMessages(now)
+	telebot.AssertNumberOfCalls(t, "SendText", 1)
+
+	//retried when due
+	telebot.On("SendText", userID, text, mock.AnythingOfType("func(url.Values)")).Return(1, nil).Once()
+
+	a.deliverMessages(now.Add(deliveryRetryDelay))
+
+	telebot.AssertNumberOfCalls(t, "SendText", 2)
+	assert.Empty(t, queue.messages)
+
+	//dropped after max attempts
+	a.enqueueMessage(queuedMessage{ChatID: userID, Text: text, At: now, Attempts: maxDeliveryAttempts - 1})
+	telebot.On("SendText", userID, text, mock.AnythingOfType("func(url.Values)")).Return(0, errors.New("Forbidden")).Once()
+
+	a.deliverMessages(now)
+
+	assert.Empty(t, queue.messages)
+}
+
+type failingRemoveQueue struct {
+	*fileQueue
+	fail bool
+}
+
+func (q *failingRemoveQueue) Remove(id string) (bool, error) {
+	if q.fail {
+		return false, errors.New("connection reset")
+	}
+	return q.fileQueue.Remove(id)
+}
+
+func TestDeliverMessagesRemoveFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fq, err := newFileQueue(filepath.Join(dir, "queue.json"))
+	assert.Nil(t, err)
+	queue := &failingRemoveQueue{fileQueue: fq, fail: true}
+
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, queue: queue}
+
+	now := time.Now()
+	id := a.enqueueMessage(queuedMessage{ChatID: userID, Text: text, At: now.Add(-time.Second)})
+
+	telebot.On("SendText", userID, text, mock.AnythingOfType("func(url.Values)")).Return(1, nil)
+
+	//claimed message is not sent again before lease expires
+	a.deliverMessages(now)
+	a.deliverMessages(now)
+	telebot.AssertNumberOfCalls(t, "SendText", 1)
+	assert.Equal(t, now.Add(deliveryLease).Unix(), fq.messages[id].At.Unix())
+
+	//sent message is removed but not sent again after lease expires
+	queue.fail = false
+	a.deliverMessages(now.Add(deliveryLease))
+
+	telebot.AssertNumberOfCalls(t, "SendText", 1)
+	assert.Empty(t, fq.messages)
+}
+
+func TestEnqueueMessageWithoutChat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	queue, err := newFileQueue(filepath.Join(dir, "queue.json"))
+	assert.Nil(t, err)
+
+	a := &application{queue: queue}
+	vm := otto.New()
+	vm.Set("sendAfter", a.getSendAfterFunc(""))
+
+	val, err := vm.Run(`sendAfter("", "hello", "1m")`)
+	assert.Nil(t, err)
+	assert.True(t, val.IsUndefined())
+	assert.Empty(t, queue.messages)
+}
+
+func TestParseTime(t *testing.T) {
+	vm := otto.New()
+	expected := time.Date(2020, time.May, 1, 10, 30, 0, 0, time.UTC)
+
+	for _, src := range []string{"new Date(Date.UTC(2020, 4, 1, 10, 30))", "1588329000000", "'2020-05-01T10:30:00Z'"} {
+		val, _ := vm.Run(src)
+		at, err := parseTime(val)
+		assert.Nil(t, err, src)
+		assert.True(t, expected.Equal(at), src)
+	}
+
+	_, err := parseTime(otto.TrueValue())
+	assert.NotNil(t, err)
+
+	_, err = parseDelay(otto.TrueValue())
+	assert.NotNil(t, err)
+
+	val, _ := otto.ToValue(1500)
+	delay, err := parseDelay(val)
+	assert.Nil(t, err)
+	assert.Equal(t, 1500*time.Millisecond, delay)
+
+	val, _ = otto.ToValue("1h30m")
+	delay, err = parseDelay(val)
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Minute, delay)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/h2non/filetype"
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	downloadTimeout        = 5 * time.Minute
+	defaultDownloadMaxSize = "20MB"
+)
+
+// maxAttachmentNameAttempts limits numbers tried to make name of downloaded file unique
+const maxAttachmentNameAttempts = 100
+
+// downloadedFile describes file saved into attachments directory, name can be passed to send
+type downloadedFile struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	MIME string `json:"mime"`
+	Size int64  `json:"size"`
+}
+
+// attachmentName checks that name denotes a file directly inside attachments directory,
+// fallback is used if name is empty
+func attachmentName(name string, fallback string) (string, error) {
+	if name == "" {
+		name = fallback
+	}
+	if name == "" || name == "." || name == ".." || name != filepath.Base(name) {
+		return "", fmt.Errorf("Invalid attachment name %q", name)
+	}
+	return name, nil
+}
+
+// saveAttachment streams content into attachments directory, content larger than DOWNLOAD_MAX_SIZE is rejected.
+// If name has no extension, extension of sniffed file type is appended
+func (a *application) saveAttachment(content io.Reader, name string) (*downloadedFile, error) {
+	tmp, err := ioutil.TempFile(a.attachmentsDir, ".download*")
+	if err != nil {
+		return nil, err
+	}
+	//temp file is removed if download fails, on success it is already renamed into attachment
+	defer os.Remove(tmp.Name())
+
+	if a.downloadMaxSize > 0 {
+		content = io.LimitReader(content, a.downloadMaxSize+1)
+	}
+	size, err := io.Copy(tmp, content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.downloadMaxSize > 0 && size > a.downloadMaxSize {
+		return nil, fmt.Errorf("File exceeds download limit of %d bytes", a.downloadMaxSize)
+	}
+
+	kind, _ := filetype.MatchFile(tmp.Name())
+	if filepath.Ext(name) == "" && kind != filetype.Unknown {
+		name += "." + kind.Extension
+	}
+
+	name, err = a.reserveAttachment(name)
+	if err != nil {
+		return nil, err
+	}
+	attachmentFile := filepath.Join(a.attachmentsDir, name)
+	if err := os.Rename(tmp.Name(), attachmentFile); err != nil {
+		os.Remove(attachmentFile)
+		return nil, err
+	}
+
+	return &downloadedFile{
+		Name: name,
+		Type: mediaType(GetFileType(attachmentFile)),
+		MIME: kind.MIME.Value,
+		Size: size,
+	}, nil
+}
+
+// reserveAttachment creates empty attachment file so that existing attachment is never overwritten,
+// number is appended to name if it is taken, e.g. chart-1.png
+func (a *application) reserveAttachment(name string) (string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 0; i < maxAttachmentNameAttempts; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		file, err := os.OpenFile(filepath.Join(a.attachmentsDir, candidate), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return candidate, file.Close()
+	}
+
+	return "", fmt.Errorf("Attachment %q already exists", name)
+}
+
+// download fetches file by url into attachments directory
+func (a *application) download(aURL string, name string) (*downloadedFile, error) {
+	client := &http.Client{Timeout: downloadTimeout}
+	resp, err := client.Get(aURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected response status %s", resp.Status)
+	}
+	if a.downloadMaxSize > 0 && resp.ContentLength > a.downloadMaxSize {
+		return nil, fmt.Errorf("File exceeds download limit of %d bytes", a.downloadMaxSize)
+	}
+
+	return a.saveAttachment(resp.Body, name)
+}
+
+func (a *application) downloadToAttachments(aURL string, name string) (*downloadedFile, error) {
+	if !isValidUrl(aURL) {
+		return nil, fmt.Errorf("Invalid url %q", aURL)
+	}
+
+	fallback := ""
+	if u, err := url.Parse(aURL); err == nil {
+		fallback = path.Base(u.Path)
+	}
+	name, err := attachmentName(name, fallback)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.download(aURL, name)
+}
+
+// downloadTelegramFile saves file sent to bot into attachments directory,
+// errors never contain file link since it contains bot token
+func (a *application) downloadTelegramFile(fileID string, name string) (*downloadedFile, error) {
+	file, err := a.tgClient.GetFileInfo(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if a.downloadMaxSize > 0 && int64(file.FileSize) > a.downloadMaxSize {
+		return nil, fmt.Errorf("File exceeds download limit of %d bytes", a.downloadMaxSize)
+	}
+
+	name, err = attachmentName(name, path.Base(file.FilePath))
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := a.download(fileURL(a.token, file.FilePath), name)
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+
+	return result, err
+}
+
+func (a *application) getDownloadToAttachmentsFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		if !call.Argument(0).IsString() {
+			log.Error("Error downloading file, url is expected")
+			return otto.Value{}
+		}
+
+		name := ""
+		if call.Argument(1).IsString() {
+			name = call.Argument(1).String()
+		}
+
+		result, err := a.downloadToAttachments(call.Argument(0).String(), name)
+		if err != nil {
+			log.Error("Error downloading file ", err)
+			return otto.Value{}
+		}
+
+		return toJsValue(call.Otto, result)
+	}
+}
+
+func (a *application) getDownloadTelegramFileFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		if !call.Argument(0).IsString() {
+			log.Error("Error downloading telegram file, file id is expected")
+			return otto.Value{}
+		}
+
+		name := ""
+		if call.Argument(1).IsString() {
+			name = call.Argument(1).String()
+		}
+
+		result, err := a.downloadTelegramFile(call.Argument(0).String(), name)
+		if err != nil {
+			log.Error("Error downloading telegram file ", err)
+			return otto.Value{}
+		}
+
+		return toJsValue(call.Otto, result)
+	}
+}
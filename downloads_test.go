@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+	"github.com/yanzay/tbot/v2"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestDownloadToAttachments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/charts/sales":
+			rw.Write(pngHeader)
+		case "/export.csv":
+			rw.Write([]byte("id,total\n1,100\n"))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "attachments")
+	defer os.RemoveAll(dir)
+
+	a := &application{attachmentsDir: dir, downloadMaxSize: 1024}
+	vm := otto.New()
+	vm.Set("downloadToAttachments", a.getDownloadToAttachmentsFunc())
+	vm.Set("baseURL", server.URL)
+
+	val, err := vm.Run(`
+		var chart = downloadToAttachments(baseURL + "/charts/sales");
+		var table = downloadToAttachments(baseURL + "/export.csv", "report.csv");
+		var again = downloadToAttachments(baseURL + "/export.csv", "report.csv");
+		[chart.name, chart.type, chart.mime, chart.size, table.name, table.type, table.size, again.name,
+		 downloadToAttachments(baseURL + "/missing"), downloadToAttachments(baseURL + "/export.csv", "../report.csv")].join(",")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, fmt.Sprintf("sales.png,photo,image/png,%d,report.csv,document,15,report-1.csv,,", len(pngHeader)), val.String())
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "report.csv"))
+	assert.Nil(t, err)
+	assert.Equal(t, "id,total\n1,100\n", string(content))
+
+	//existing attachment is not overwritten
+	ioutil.WriteFile(filepath.Join(dir, "report.csv"), []byte("edited"), 0644)
+	result, err := a.downloadToAttachments(server.URL+"/export.csv", "report.csv")
+	assert.Nil(t, err)
+	assert.Equal(t, "report-2.csv", result.Name)
+
+	content, _ = ioutil.ReadFile(filepath.Join(dir, "report.csv"))
+	assert.Equal(t, "edited", string(content))
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 4)
+}
+
+func TestDownloadSizeLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		//no content length, the limit is checked while streaming
+		rw.(http.Flusher).Flush()
+		rw.Write(make([]byte, 2048))
+	}))
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "attachments")
+	defer os.RemoveAll(dir)
+
+	a := &application{attachmentsDir: dir, downloadMaxSize: 1024}
+	result, err := a.downloadToAttachments(server.URL+"/big.bin", "")
+
+	assert.Nil(t, result)
+	assert.EqualError(t, err, "File exceeds download limit of 1024 bytes")
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Empty(t, files)
+}
+
+func TestDownloadTelegramFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/file/bottoken/photos/file_1.jpg" {
+			rw.Write(pngHeader)
+			return
+		}
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	defaultURL := apiBaseURL
+	apiBaseURL = server.URL
+	defer func() { apiBaseURL = defaultURL }()
+
+	dir, _ := ioutil.TempDir("", "attachments")
+	defer os.RemoveAll(dir)
+
+	telebot := &mocks.Telebot{}
+	telebot.On("GetFileInfo", "photo-id").Return(&tbot.File{FilePath: "photos/file_1.jpg", FileSize: len(pngHeader)}, nil)
+	telebot.On("GetFileInfo", "large-id").Return(&tbot.File{FilePath: "videos/file_2.mp4", FileSize: 4096}, nil)
+	a := &application{tgClient: telebot, token: "token", attachmentsDir: dir, downloadMaxSize: 1024}
+
+	vm := otto.New()
+	vm.Set("downloadTelegramFile", a.getDownloadTelegramFileFunc())
+	val, err := vm.Run(`
+		var photo = downloadTelegramFile("photo-id");
+		var avatar = downloadTelegramFile("photo-id", "avatar");
+		[photo.name, photo.type, avatar.name, downloadTelegramFile("large-id")].join(",")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "file_1.jpg,photo,avatar.png,", val.String())
+
+	server.Close()
+	_, err = a.downloadTelegramFile("photo-id", "")
+
+	assert.NotNil(t, err)
+	assert.NotContains(t, err.Error(), "token")
+}
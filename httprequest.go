@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+)
+
+const defaultHTTPTimeout = 30 * time.Second
+
+// maxHTTPResponseSize limits body of response read into memory
+const maxHTTPResponseSize = 10 << 20
+
+// httpResponse is result of httpRequest, status is 0 and error is set if request failed without response
+type httpResponse struct {
+	Status  int               `json:"status"`
+	OK      bool              `json:"ok"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+	JSON    interface{}       `json:"json"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// requestBody encodes body given in json, form, multipart or raw field of request object and returns its content type
+func (a *application) requestBody(spec map[string]interface{}) (io.Reader, string, error) {
+	if payload, ok := spec["json"]; ok {
+		data, err := json.Marshal(payload)
+		return bytes.NewReader(data), "application/json", err
+	}
+
+	if form, ok := spec["form"].(map[string]interface{}); ok {
+		values := url.Values{}
+		for key, val := range form {
+			values.Set(key, fmt.Sprintf("%v", val))
+		}
+		return strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", nil
+	}
+
+	if parts, ok := spec["multipart"].(map[string]interface{}); ok {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		for field, val := range parts {
+			//object with file field is a file from attachments directory, paths outside of it are rejected
+			if part, ok := val.(map[string]interface{}); ok {
+				file, _ := stringField(part, "file")
+				name, err := attachmentName(file, "")
+				if err != nil {
+					return nil, "", err
+				}
+				if err := writeFilePart(mw, field, filepath.Join(a.attachmentsDir, name)); err != nil {
+					return nil, "", err
+				}
+				continue
+			}
+			if err := mw.WriteField(field, fmt.Sprintf("%v", val)); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, "", err
+		}
+		return body, mw.FormDataContentType(), nil
+	}
+
+	if raw, ok := stringField(spec, "raw"); ok {
+		return strings.NewReader(raw), "text/plain; charset=utf-8", nil
+	}
+
+	return nil, "", nil
+}
+
+// newHTTPRequest builds request described by object with method, url, query, headers, auth and body fields
+func (a *application) newHTTPRequest(spec map[string]interface{}) (*http.Request, error) {
+	aURL, ok := stringField(spec, "url")
+	if !ok {
+		return nil, fmt.Errorf("Request url is missing")
+	}
+
+	body, contentType, err := a.requestBody(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	method, ok := stringField(spec, "method")
+	if !ok && body != nil {
+		method = http.MethodPost
+	} else if !ok {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(method), aURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if query, ok := spec["query"].(map[string]interface{}); ok {
+		params := req.URL.Query()
+		for key, val := range query {
+			params.Set(key, fmt.Sprintf("%v", val))
+		}
+		req.URL.RawQuery = params.Encode()
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if headers, ok := spec["headers"].(map[string]interface{}); ok {
+		for key, val := range headers {
+			req.Header.Set(key, fmt.Sprintf("%v", val))
+		}
+	}
+
+	if auth, ok := spec["auth"].(map[string]interface{}); ok {
+		if token, ok := stringField(auth, "bearer"); ok {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else {
+			username, _ := stringField(auth, "username", "user")
+			password, _ := stringField(auth, "password")
+			req.SetBasicAuth(username, password)
+		}
+	}
+
+	return req, nil
+}
+
+// httpRequest sends request and returns response with any status, network errors are returned in error field
+func (a *application) httpRequest(spec map[string]interface{}, timeout time.Duration) *httpResponse {
+	req, err := a.newHTTPRequest(spec)
+	if err != nil {
+		log.Error("Error building http request ", err)
+		return &httpResponse{Error: err.Error()}
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Error("Error sending http request ", err)
+		return &httpResponse{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseSize+1))
+	if err == nil && len(data) > maxHTTPResponseSize {
+		err = fmt.Errorf("Response body exceeds %d bytes", maxHTTPResponseSize)
+	}
+	if err != nil {
+		log.Error("Error reading http response ", err)
+		return &httpResponse{Status: resp.StatusCode, Error: err.Error()}
+	}
+
+	result := &httpResponse{
+		Status:  resp.StatusCode,
+		OK:      resp.StatusCode >= 200 && resp.StatusCode < 300,
+		Headers: map[string]string{},
+		Body:    string(data),
+	}
+	//repeated headers are joined as allowed by http spec
+	for key, values := range resp.Header {
+		result.Headers[strings.ToLower(key)] = strings.Join(values, ", ")
+	}
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		if err := json.Unmarshal(data, &result.JSON); err != nil {
+			log.Error("Error parsing json of http response ", err)
+		}
+	}
+
+	return result
+}
+
+// getHTTPRequestFunc returns response object, request which can not be built is reported in its error field like network errors
+func (a *application) getHTTPRequestFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		specInterface, err := exportJSON(call.Otto, call.Argument(0))
+		if err != nil {
+			log.Error("Error reading http request ", err)
+			return toJsValue(call.Otto, &httpResponse{Error: err.Error()})
+		}
+		spec, ok := specInterface.(map[string]interface{})
+		if !ok {
+			log.Error("Error sending http request, request object is expected")
+			return toJsValue(call.Otto, &httpResponse{Error: "Request object is expected"})
+		}
+
+		timeout := defaultHTTPTimeout
+		if val, err := call.Argument(0).Object().Get("timeout"); err == nil && val.IsDefined() {
+			if timeout, err = parseDelay(val); err != nil {
+				log.Error("Error sending http request, invalid timeout ", err)
+				return toJsValue(call.Otto, &httpResponse{Error: err.Error()})
+			}
+		}
+
+		return toJsValue(call.Otto, a.httpRequest(spec, timeout))
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/contacts":
+			assert.Equal(t, "POST", r.Method)
+			assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.Equal(t, "crm", r.URL.Query().Get("source"))
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.JSONEq(t, `{"name":"John","tags":["vip",1]}`, string(body))
+
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Header().Set("X-Request-Id", "42")
+			rw.Header().Add("Cache-Control", "no-cache")
+			rw.Header().Add("Cache-Control", "no-store")
+			rw.WriteHeader(http.StatusCreated)
+			rw.Write([]byte(`{"id":7}`))
+		case "/login":
+			user, password, _ := r.BasicAuth()
+			assert.Equal(t, "admin:pass", user+":"+password)
+			r.ParseForm()
+			assert.Equal(t, "PATCH", r.Method)
+			assert.Equal(t, "1", r.PostForm.Get("remember"))
+
+			rw.WriteHeader(http.StatusUnauthorized)
+			rw.Write([]byte("Unauthorized"))
+		case "/upload":
+			file, header, err := r.FormFile("document")
+			assert.Nil(t, err)
+			assert.Equal(t, "document.txt", header.Filename)
+			content, _ := ioutil.ReadAll(file)
+			assert.Equal(t, "test", string(content))
+			assert.Equal(t, "report", r.FormValue("title"))
+		case "/large":
+			rw.Write(bytes.Repeat([]byte("a"), maxHTTPResponseSize+1))
+		case "/notes/1":
+			assert.Equal(t, "PUT", r.Method)
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.Equal(t, "plain note", string(body))
+			assert.Equal(t, "text/markdown", r.Header.Get("Content-Type"))
+		}
+	}))
+	defer server.Close()
+
+	a := &application{attachmentsDir: attachmentsDir}
+	vm := otto.New()
+	vm.Set("httpRequest", a.getHTTPRequestFunc())
+	vm.Set("baseURL", server.URL)
+
+	val, err := vm.Run(`
+		var created = httpRequest({url: baseURL + "/contacts", query: {source: "crm"}, json: {name: "John", tags: ["vip", 1]}, auth: {bearer: "secret"}});
+		var denied = httpRequest({method: "patch", url: baseURL + "/login", form: {remember: 1}, auth: {username: "admin", password: "pass"}, timeout: "5s"});
+		var uploaded = httpRequest({url: baseURL + "/upload", multipart: {title: "report", document: {file: "document.txt"}}});
+		var updated = httpRequest({method: "PUT", url: baseURL + "/notes/1", raw: "plain note", headers: {"Content-Type": "text/markdown"}});
+		var large = httpRequest({url: baseURL + "/large"});
+		[created.status, created.ok, created.json.id, created.headers["x-request-id"], created.headers["cache-control"],
+		 denied.status, denied.ok, denied.body, denied.json,
+		 uploaded.status, updated.status, large.status, large.body, large.error].join(",")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "201,true,7,42,no-cache, no-store,401,false,Unauthorized,,200,200,200,,Response body exceeds 10485760 bytes", val.String())
+}
+
+func TestHTTPRequestNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	a := &application{}
+	vm := otto.New()
+	vm.Set("httpRequest", a.getHTTPRequestFunc())
+	vm.Set("baseURL", server.URL)
+
+	val, err := vm.Run(`var resp = httpRequest({url: baseURL, timeout: 1000}); [resp.status, resp.ok, resp.error != ""].join(",")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "0,false,true", val.String())
+}
+
+func TestHTTPRequestBuildError(t *testing.T) {
+	a := &application{attachmentsDir: attachmentsDir}
+	vm := otto.New()
+	vm.Set("httpRequest", a.getHTTPRequestFunc())
+
+	//files outside of attachments directory are not uploaded
+	for _, script := range []string{
+		`httpRequest({url: "http://localhost", multipart: {file: {file: "../../.env"}}})`,
+		`httpRequest({url: "http://localhost", multipart: {file: {file: ""}}})`,
+		`httpRequest({url: "http://localhost", timeout: "soon"})`,
+		`httpRequest("http://localhost")`,
+	} {
+		val, err := vm.Run(`var resp = ` + script + `; [resp.status, resp.ok, resp.error != ""].join(",")`)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "0,false,true", val.String(), script)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+	"github.com/yanzay/tbot/v2"
+)
+
+// inlineResult converts a result object of script to inline query result, short fields are expanded to the ones telegram expects
+func inlineResult(index int, item map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(item))
+	for key, val := range item {
+		result[key] = val
+	}
+
+	resultType, ok := stringField(item, "type")
+	if !ok {
+		resultType = "article"
+	}
+	result["type"] = resultType
+	if id, ok := stringField(item, "id"); ok {
+		result["id"] = id
+	} else {
+		result["id"] = strconv.Itoa(index)
+	}
+	if thumb, ok := stringField(item, "thumb"); ok {
+		delete(result, "thumb")
+		result["thumb_url"] = thumb
+	}
+	if _, ok := item["caption"]; ok {
+		result["parse_mode"] = "HTML"
+	}
+	if options, ok := item["options"]; ok {
+		delete(result, "options")
+		result["reply_markup"] = buildInlineOptions(options)
+	}
+
+	switch resultType {
+	case "article":
+		if text, ok := stringField(item, "text"); ok {
+			delete(result, "text")
+			result["input_message_content"] = map[string]interface{}{"message_text": text, "parse_mode": "HTML"}
+		}
+	case "photo":
+		if photo, ok := stringField(item, "photo"); ok {
+			delete(result, "photo")
+			if isValidUrl(photo) {
+				result["photo_url"] = photo
+				if _, ok := result["thumb_url"]; !ok {
+					result["thumb_url"] = photo
+				}
+			} else {
+				result["photo_file_id"] = photo
+			}
+		}
+	case "document":
+		if document, ok := stringField(item, "document"); ok {
+			delete(result, "document")
+			if isValidUrl(document) {
+				result["document_url"] = document
+			} else {
+				result["document_file_id"] = document
+			}
+		}
+	}
+
+	return result
+}
+
+// answerInlineQuery sends results of inline query, settings are cache_time, next_offset and is_personal
+func (a *application) answerInlineQuery(queryID string, items []interface{}, settings map[string]interface{}) {
+	results := make([]map[string]interface{}, 0, len(items))
+	for i, item := range items {
+		if theMap, ok := item.(map[string]interface{}); ok {
+			results = append(results, inlineResult(i, theMap))
+		}
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		log.Error("Error encoding inline query results ", err)
+		return
+	}
+
+	err = a.tgClient.AnswerInline(queryID, string(data), func(r url.Values) {
+		for _, key := range []string{"cache_time", "next_offset", "is_personal"} {
+			if val, ok := stringField(settings, key); ok {
+				r.Set(key, val)
+			}
+		}
+	})
+	if err != nil {
+		log.Error("Error answering inline query ", err)
+	}
+}
+
+// handleInlineQuery calls bot.onInlineQuery bound to the chat of the user, handler answers the query with answerInlineQuery
+func (a *application) handleInlineQuery(iq *tbot.InlineQuery) {
+	if !a.hasHandler("onInlineQuery") {
+		return
+	}
+
+	if _, err := a.callHandler(strconv.Itoa(iq.From.ID), "onInlineQuery", iq); err != nil {
+		log.Error("Error in handleInlineQuery ", err)
+	}
+}
+
+func (a *application) handleChosenInlineResult(cir *tbot.ChosenInlineResult) {
+	if !a.hasHandler("onChosenInlineResult") {
+		return
+	}
+
+	if _, err := a.callHandler(strconv.Itoa(cir.From.ID), "onChosenInlineResult", cir); err != nil {
+		log.Error("Error in handleChosenInlineResult ", err)
+	}
+}
+
+func (a *application) getAnswerInlineQueryFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		queryID, _ := call.Argument(0).ToString()
+
+		resultsInterface, err := exportJSON(call.Otto, call.Argument(1))
+		if err != nil {
+			log.Error("Error reading inline query results ", err)
+			return otto.Value{}
+		}
+		items, _ := toSlice(resultsInterface)
+
+		settingsInterface, _ := exportJSON(call.Otto, call.Argument(2))
+		settings, _ := settingsInterface.(map[string]interface{})
+
+		a.answerInlineQuery(queryID, items, settings)
+
+		return otto.Value{}
+	}
+}
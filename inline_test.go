@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestInlineQuery(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	vm := VmFactoryImpl{}.GetVm()
+	a := &application{tgClient: telebot, vmTemplate: vm}
+	vm.Set("answerInlineQuery", a.getAnswerInlineQueryFunc())
+	vm.Run(`bot = {
+		onInlineQuery: function (query) {
+			answerInlineQuery(query.ID, [
+				{title: "Echo", text: "<b>" + query.Query + "</b>", options: [[{text: "Like", data: "like"}]]},
+				{type: "photo", id: "cat", photo: "https://example.com/cat.jpg", caption: "Cat"},
+				{type: "document", title: "Manual", document: "fileID"}
+			], {cache_time: 0, next_offset: "20", is_personal: true})
+		}
+	}`)
+
+	var results []map[string]interface{}
+	request := url.Values{}
+	telebot.On("AnswerInline", "q1", mock.AnythingOfType("string"), mock.AnythingOfType("func(url.Values)")).Return(nil).Run(func(args mock.Arguments) {
+		json.Unmarshal([]byte(args.String(1)), &results)
+		args.Get(2).(func(url.Values))(request)
+	})
+
+	update := &Update{}
+	json.Unmarshal([]byte(`{"update_id":1,"inline_query":{"id":"q1","from":{"id":42},"query":"hello","offset":""}}`), update)
+	a.handleUpdate(update)
+
+	telebot.AssertExpectations(t)
+	assert.Equal(t, url.Values{"cache_time": {"0"}, "next_offset": {"20"}, "is_personal": {"true"}}, request)
+	assert.Len(t, results, 3)
+
+	assert.Equal(t, "article", results[0]["type"])
+	assert.Equal(t, "0", results[0]["id"])
+	assert.Equal(t, map[string]interface{}{"message_text": "<b>hello</b>", "parse_mode": "HTML"}, results[0]["input_message_content"])
+	assert.Equal(t, map[string]interface{}{"inline_keyboard": []interface{}{[]interface{}{map[string]interface{}{"text": "Like", "callback_data": "like"}}}}, results[0]["reply_markup"])
+
+	assert.Equal(t, "cat", results[1]["id"])
+	assert.Equal(t, "https://example.com/cat.jpg", results[1]["photo_url"])
+	assert.Equal(t, "https://example.com/cat.jpg", results[1]["thumb_url"])
+	assert.Equal(t, "HTML", results[1]["parse_mode"])
+
+	assert.Equal(t, "2", results[2]["id"])
+	assert.Equal(t, "fileID", results[2]["document_file_id"])
+}
+
+func TestChosenInlineResult(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	vm := VmFactoryImpl{}.GetVm()
+	a := &application{tgClient: telebot, vmTemplate: vm}
+	vm.Set("send", a.getSendFunc(""))
+	vm.Run(`bot = {
+		onChosenInlineResult: function (result) { send("You have chosen " + result.ResultID + " for " + result.Query) }
+	}`)
+
+	telebot.On("SendText", "42", "You have chosen cat for hello", mock.AnythingOfType("func(url.Values)")).Return(1, nil)
+
+	update := &Update{}
+	json.Unmarshal([]byte(`{"update_id":1,"chosen_inline_result":{"result_id":"cat","from":{"id":42},"query":"hello"}}`), update)
+	a.handleUpdate(update)
+
+	telebot.AssertExpectations(t)
+}
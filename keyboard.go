@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+
+	"github.com/yanzay/tbot/v2"
+)
+
+// InlineKeyboardMarkup mirrors bot api type, tbot lacks pay buttons and declares login_url incorrectly
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+type InlineKeyboardButton struct {
+	Text                         string    `json:"text"`
+	URL                          string    `json:"url,omitempty"`
+	LoginURL                     *LoginURL `json:"login_url,omitempty"`
+	CallbackData                 string    `json:"callback_data,omitempty"`
+	SwitchInlineQuery            *string   `json:"switch_inline_query,omitempty"`
+	SwitchInlineQueryCurrentChat *string   `json:"switch_inline_query_current_chat,omitempty"`
+	Pay                          bool      `json:"pay,omitempty"`
+}
+
+type LoginURL struct {
+	URL                string `json:"url"`
+	ForwardText        string `json:"forward_text,omitempty"`
+	BotUsername        string `json:"bot_username,omitempty"`
+	RequestWriteAccess bool   `json:"request_write_access,omitempty"`
+}
+
+// ReplyKeyboardMarkup mirrors bot api type, tbot lacks persistent keyboards, placeholders and poll buttons
+type ReplyKeyboardMarkup struct {
+	Keyboard              [][]KeyboardButton `json:"keyboard"`
+	IsPersistent          bool               `json:"is_persistent,omitempty"`
+	ResizeKeyboard        bool               `json:"resize_keyboard"`
+	OneTimeKeyboard       bool               `json:"one_time_keyboard"`
+	InputFieldPlaceholder string             `json:"input_field_placeholder,omitempty"`
+	Selective             bool               `json:"selective"`
+}
+
+type KeyboardButton struct {
+	Text            string                  `json:"text"`
+	RequestContact  bool                    `json:"request_contact,omitempty"`
+	RequestLocation bool                    `json:"request_location,omitempty"`
+	RequestPoll     *KeyboardButtonPollType `json:"request_poll,omitempty"`
+}
+
+type KeyboardButtonPollType struct {
+	Type string `json:"type,omitempty"`
+}
+
+// inlineButtonKeys are keys only buttons of inline keyboard have
+var inlineButtonKeys = []string{"data", "callback_data", "url", "switch_inline_query", "switch_inline_query_current_chat", "login_url", "pay"}
+
+// optReplyMarkup sets any keyboard markup as reply_markup of a request
+func optReplyMarkup(markup interface{}) func(url.Values) {
+	return func(r url.Values) {
+		data, err := json.Marshal(markup)
+		if err != nil {
+			return
+		}
+		r.Set("reply_markup", string(data))
+	}
+}
+
+// optNone sets no options of a request
+func optNone(url.Values) {}
+
+// keyboardOption chooses keyboard of a message sent by send: custom keyboard if options have any,
+// otherwise inline keyboard if any, otherwise custom keyboard shown before is removed. Reports whether any keyboard is set
+func keyboardOption(options interface{}, inlineOptions interface{}) (func(url.Values), bool) {
+	if replyKeyboard := buildReplyOptions(options); len(replyKeyboard.Keyboard) > 0 {
+		return optReplyMarkup(replyKeyboard), true
+	}
+	if inlineKeyboard := buildInlineOptions(inlineOptions); len(inlineKeyboard.InlineKeyboard) > 0 {
+		return optReplyMarkup(inlineKeyboard), true
+	}
+
+	return tbot.OptReplyKeyboardRemove, false
+}
+
+// buildLegacyInlineRow converts a row given as map of text to callback data or url, buttons are ordered by value
+func buildLegacyInlineRow(row map[string]interface{}) []InlineKeyboardButton {
+	buttons := make([]InlineKeyboardButton, 0, len(row))
+	for text, val := range row {
+		value := fmt.Sprintf("%v", val)
+		if isValidUrl(value) {
+			buttons = append(buttons, InlineKeyboardButton{Text: text, URL: value})
+		} else {
+			buttons = append(buttons, InlineKeyboardButton{Text: text, CallbackData: value})
+		}
+	}
+	sort.Slice(buttons, func(i, j int) bool {
+		vi, vj := buttons[i].URL+buttons[i].CallbackData, buttons[j].URL+buttons[j].CallbackData
+		if vi == vj {
+			return buttons[i].Text < buttons[j].Text
+		}
+		return vi < vj
+	})
+
+	return buttons
+}
+
+// buildInlineButton converts a button object like {text: "Docs", url: "https://..."} of inline row,
+// button without action next to buttons with actions sends its text as callback data
+func buildInlineButton(button map[string]interface{}) InlineKeyboardButton {
+	b := InlineKeyboardButton{}
+	b.Text, _ = stringField(button, "text")
+
+	if data, ok := stringField(button, "callback_data", "data"); ok {
+		b.CallbackData = data
+	}
+	if link, ok := stringField(button, "url"); ok {
+		b.URL = link
+	}
+	if query, ok := stringField(button, "switch_inline_query"); ok {
+		b.SwitchInlineQuery = &query
+	}
+	if query, ok := stringField(button, "switch_inline_query_current_chat"); ok {
+		b.SwitchInlineQueryCurrentChat = &query
+	}
+	switch login := button["login_url"].(type) {
+	case string:
+		b.LoginURL = &LoginURL{URL: login}
+	case map[string]interface{}:
+		b.LoginURL = &LoginURL{}
+		b.LoginURL.URL, _ = stringField(login, "url")
+		b.LoginURL.ForwardText, _ = stringField(login, "forward_text")
+		b.LoginURL.BotUsername, _ = stringField(login, "bot_username")
+		b.LoginURL.RequestWriteAccess = boolField(login, "request_write_access")
+	}
+	b.Pay = boolField(button, "pay")
+
+	if b.CallbackData == "" && b.URL == "" && b.LoginURL == nil && b.SwitchInlineQuery == nil &&
+		b.SwitchInlineQueryCurrentChat == nil && !b.Pay {
+		b.CallbackData = b.Text
+	}
+
+	return b
+}
+
+// buildReplyButton converts a button object like {text: "Share phone", request_contact: true},
+// request_poll is either true or poll type ("quiz" or "regular")
+func buildReplyButton(button map[string]interface{}) KeyboardButton {
+	b := KeyboardButton{}
+	b.Text, _ = stringField(button, "text")
+	b.RequestContact = boolField(button, "request_contact")
+	b.RequestLocation = boolField(button, "request_location")
+
+	switch poll := button["request_poll"].(type) {
+	case bool:
+		if poll {
+			b.RequestPoll = &KeyboardButtonPollType{}
+		}
+	case string:
+		b.RequestPoll = &KeyboardButtonPollType{Type: poll}
+	}
+
+	return b
+}
+
+// stringField returns value of the first present key of an object
+func stringField(object map[string]interface{}, keys ...string) (string, bool) {
+	for _, key := range keys {
+		if val, ok := object[key]; ok && val != nil {
+			return fmt.Sprintf("%v", val), true
+		}
+	}
+
+	return "", false
+}
+
+func boolField(object map[string]interface{}, key string) bool {
+	flag, _ := object[key].(bool)
+	return flag
+}
+
+// isInlineRow reports whether keyboard row consists of button objects and any of them has inline action,
+// so that row of plain objects like [{text: "Yes"}, {text: "No"}] stays custom keyboard row
+func isInlineRow(buttons []interface{}) bool {
+	if !isObjectRow(buttons) {
+		return false
+	}
+	for _, button := range buttons {
+		theMap := button.(map[string]interface{})
+		for _, key := range inlineButtonKeys {
+			if _, ok := theMap[key]; ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isObjectRow reports whether keyboard row consists of button objects only
+func isObjectRow(buttons []interface{}) bool {
+	for _, button := range buttons {
+		if _, ok := button.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+
+	return len(buttons) > 0
+}
+
+// toSlice converts exported js array of any element type to a list
+func toSlice(val interface{}) ([]interface{}, bool) {
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Slice {
+		return nil, false
+	}
+
+	list := make([]interface{}, v.Len())
+	for i := range list {
+		list[i] = v.Index(i).Interface()
+	}
+
+	return list, true
+}
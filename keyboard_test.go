@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildInlineOptions(t *testing.T) {
+	//legacy map rows are ordered by value and buttons with the same data are kept
+	markup := buildInlineOptions([]map[string]interface{}{{"Two": "2", "One": "1", "Uno": "1", "Site": "https://example.com"}})
+
+	assert.Equal(t, [][]InlineKeyboardButton{{
+		{Text: "One", CallbackData: "1"},
+		{Text: "Uno", CallbackData: "1"},
+		{Text: "Two", CallbackData: "2"},
+		{Text: "Site", URL: "https://example.com"},
+	}}, markup.InlineKeyboard)
+
+	//button objects keep order
+	vm := otto.New()
+	val, _ := vm.Run(`[
+		[{text: "Yes", data: "y"}, {text: "Docs", url: "https://example.com/docs"}, {text: "Share", switch_inline_query: ""}],
+		[{text: "Login", login_url: {url: "https://example.com/login", request_write_access: true}}, {text: "Pay", pay: true}, {text: "No", callback_data: 0}, {text: "Plain"}]
+	]`)
+	options, _ := exportJSON(vm, val)
+
+	empty := ""
+	expected := [][]InlineKeyboardButton{
+		{
+			{Text: "Yes", CallbackData: "y"},
+			{Text: "Docs", URL: "https://example.com/docs"},
+			{Text: "Share", SwitchInlineQuery: &empty},
+		},
+		{
+			{Text: "Login", LoginURL: &LoginURL{URL: "https://example.com/login", RequestWriteAccess: true}},
+			{Text: "Pay", Pay: true},
+			{Text: "No", CallbackData: "0"},
+			{Text: "Plain", CallbackData: "Plain"},
+		},
+	}
+	assert.Equal(t, expected, buildInlineOptions(options).InlineKeyboard)
+
+	//options restored from json
+	data, _ := json.Marshal(options)
+	var restored interface{}
+	json.Unmarshal(data, &restored)
+
+	assert.Equal(t, expected, buildInlineOptions(restored).InlineKeyboard)
+
+	//custom keyboard rows are not inline options
+	reply, inline := parseSendOptions([]interface{}{[]interface{}{"one", "two"}, map[string]interface{}{"One": "1"}})
+	assert.Equal(t, []interface{}{[]interface{}{"one", "two"}}, reply)
+	assert.Equal(t, 1, len(inline))
+
+	assert.Equal(t, 0, len(buildInlineOptions(nil).InlineKeyboard))
+}
+
+func TestBuildReplyOptions(t *testing.T) {
+	//plain rows keep previous defaults
+	markup := buildReplyOptions([][]string{{"one", "two"}})
+
+	assert.Equal(t, &ReplyKeyboardMarkup{
+		Keyboard:        [][]KeyboardButton{{{Text: "one"}, {Text: "two"}}},
+		OneTimeKeyboard: true,
+		ResizeKeyboard:  true,
+	}, markup)
+
+	//rows with request buttons are custom keyboard rows
+	vm := otto.New()
+	val, _ := vm.Run(`[[{text: "Share phone", request_contact: true}, "Cancel"], [{text: "Share location", request_location: true}, {text: "Quiz", request_poll: "quiz"}]]`)
+	options, _ := exportJSON(vm, val)
+
+	reply, inline := parseSendOptions(options)
+	assert.Equal(t, 0, len(inline))
+	assert.Equal(t, [][]KeyboardButton{
+		{{Text: "Share phone", RequestContact: true}, {Text: "Cancel"}},
+		{{Text: "Share location", RequestLocation: true}, {Text: "Quiz", RequestPoll: &KeyboardButtonPollType{Type: "quiz"}}},
+	}, buildReplyOptions(reply).Keyboard)
+
+	//keyboard settings
+	val, _ = vm.Run(`({keyboard: [[{text: "Menu"}, {text: "Poll", request_poll: true}]], persistent: true, selective: true, one_time: false, placeholder: "Choose"})`)
+	options, _ = exportJSON(vm, val)
+
+	reply, inline = parseSendOptions(options)
+	assert.Equal(t, 0, len(inline))
+	assert.Equal(t, &ReplyKeyboardMarkup{
+		Keyboard:              [][]KeyboardButton{{{Text: "Menu"}, {Text: "Poll", RequestPoll: &KeyboardButtonPollType{}}}},
+		IsPersistent:          true,
+		ResizeKeyboard:        true,
+		InputFieldPlaceholder: "Choose",
+		Selective:             true,
+	}, buildReplyOptions(reply))
+
+	//rows of plain button objects are custom keyboard rows
+	val, _ = vm.Run(`[[{text: "Yes"}, {text: "No"}], [{text: "Docs", url: "https://example.com/docs"}, {text: "Plain"}]]`)
+	options, _ = exportJSON(vm, val)
+
+	reply, inline = parseSendOptions(options)
+	assert.Equal(t, 1, len(inline))
+	assert.Equal(t, [][]KeyboardButton{{{Text: "Yes"}, {Text: "No"}}}, buildReplyOptions(reply).Keyboard)
+	assert.Equal(t, [][]InlineKeyboardButton{{{Text: "Docs", URL: "https://example.com/docs"}, {Text: "Plain", CallbackData: "Plain"}}},
+		buildInlineOptions(inline).InlineKeyboard)
+
+	//replaceOptions and editMessage do not take rows of plain button objects as inline either
+	assert.Equal(t, 0, len(buildInlineOptions([]interface{}{[]interface{}{map[string]interface{}{"text": "Yes"}, map[string]interface{}{"text": "No"}}}).InlineKeyboard))
+
+	assert.Equal(t, 0, len(buildReplyOptions(nil).Keyboard))
+}
+
+func TestOptReplyMarkup(t *testing.T) {
+	r := url.Values{}
+	optReplyMarkup(&InlineKeyboardMarkup{InlineKeyboard: [][]InlineKeyboardButton{{{Text: "Pay", Pay: true}}}})(r)
+
+	assert.Equal(t, `{"inline_keyboard":[[{"text":"Pay","pay":true}]]}`, r.Get("reply_markup"))
+}
+
+func TestKeyboardOption(t *testing.T) {
+	r := url.Values{}
+	option, ok := keyboardOption([]interface{}{[]interface{}{"Yes"}}, []interface{}{map[string]interface{}{"Docs": "https://example.com"}})
+	option(r)
+
+	assert.True(t, ok)
+	assert.Contains(t, r.Get("reply_markup"), `"keyboard":[[{"text":"Yes"}]]`)
+
+	r = url.Values{}
+	option, ok = keyboardOption(nil, []interface{}{map[string]interface{}{"Docs": "https://example.com"}})
+	option(r)
+
+	assert.True(t, ok)
+	assert.Equal(t, `{"inline_keyboard":[[{"text":"Docs","url":"https://example.com"}]]}`, r.Get("reply_markup"))
+
+	r = url.Values{}
+	option, ok = keyboardOption(nil, nil)
+	option(r)
+
+	assert.False(t, ok)
+	assert.Contains(t, r.Get("reply_markup"), `"remove_keyboard":true`)
+}
@@ -23,7 +23,7 @@ func main() {
 	bot := tbot.New(token)
 
 	app := &application{
-		tgClient:       &TbotWrapper{bot.Client()},
+		tgClient:       &TbotWrapper{Client: bot.Client(), token: token},
 		attachmentsDir: GetEnv("ATTACHMENTS_DIR", "attachments"),
 		token:          token,
 		vmFactory:      VmFactoryImpl{},
@@ -33,9 +33,12 @@ func main() {
 		log.Fatal("Error initializing app ", e)
 	}
 
-	//bind handlers
-	bot.HandleMessage("", app.messageHandler)
-	bot.HandleCallback(app.callbackHandler)
+	//reload scripts on SIGHUP and optionally on file changes
+	watchInterval, err := time.ParseDuration(GetEnv("SCRIPTS_WATCH", "0s"))
+	if err != nil {
+		log.Error("Error parsing time duration for scripts watch, watching is disabled ", err)
+	}
+	go app.watchScripts(watchInterval)
 
 	go func() {
 		//let bot connect
@@ -43,6 +46,11 @@ func main() {
 		app.onInit()
 	}()
 
-	//start bot
-	log.Fatal(bot.Start())
+	//receive updates via webhook if configured
+	if webhook := getWebhookConfig(); webhook.url != "" {
+		log.Fatal(serveWebhook(token, webhook, app.handleUpdate))
+	}
+
+	//receive updates in long polling mode, tbot server does not deliver all update types
+	log.Fatal(pollUpdates(token, app.handleUpdate))
 }
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+	"github.com/yanzay/tbot/v2"
+)
+
+// ChatMemberUpdated is a change of member status in a chat, tbot does not support chat member updates
+type ChatMemberUpdated struct {
+	Chat          tbot.Chat        `json:"chat"`
+	From          tbot.User        `json:"from"`
+	Date          int              `json:"date"`
+	OldChatMember *tbot.ChatMember `json:"old_chat_member"`
+	NewChatMember *tbot.ChatMember `json:"new_chat_member"`
+	InviteLink    *ChatInviteLink  `json:"invite_link"`
+}
+
+// ChatJoinRequest is a request to join a chat by invite link requiring approval
+type ChatJoinRequest struct {
+	Chat       tbot.Chat       `json:"chat"`
+	From       tbot.User       `json:"from"`
+	Date       int             `json:"date"`
+	Bio        string          `json:"bio"`
+	InviteLink *ChatInviteLink `json:"invite_link"`
+}
+
+// ChatInviteLink is an invite link the user joined or requested to join with
+type ChatInviteLink struct {
+	InviteLink         string     `json:"invite_link"`
+	Name               string     `json:"name"`
+	Creator            *tbot.User `json:"creator"`
+	CreatesJoinRequest bool       `json:"creates_join_request"`
+	IsPrimary          bool       `json:"is_primary"`
+	IsRevoked          bool       `json:"is_revoked"`
+	ExpireDate         int        `json:"expire_date"`
+	MemberLimit        int        `json:"member_limit"`
+}
+
+func isMember(member *tbot.ChatMember) bool {
+	switch member.Status {
+	case "creator", "administrator", "member":
+		return true
+	case "restricted":
+		return member.IsMember
+	}
+	return false
+}
+
+func isAdmin(member *tbot.ChatMember) bool {
+	return member.Status == "creator" || member.Status == "administrator"
+}
+
+// memberEvent names the change of member status: joined, left, banned, unbanned, promoted, demoted, restricted or changed.
+// In private chats the bot is blocked and unblocked by the user instead
+func memberEvent(cmu *ChatMemberUpdated) string {
+	oldMember, newMember := cmu.OldChatMember, cmu.NewChatMember
+	if oldMember == nil || newMember == nil {
+		return "changed"
+	}
+
+	if cmu.Chat.Type == "private" {
+		switch {
+		case newMember.Status == "kicked":
+			return "blocked"
+		case oldMember.Status == "kicked":
+			return "unblocked"
+		}
+	}
+
+	switch {
+	case !isMember(oldMember) && isMember(newMember):
+		return "joined"
+	case newMember.Status == "kicked":
+		return "banned"
+	case oldMember.Status == "kicked":
+		return "unbanned"
+	case isMember(oldMember) && !isMember(newMember):
+		return "left"
+	case !isAdmin(oldMember) && isAdmin(newMember):
+		return "promoted"
+	case isAdmin(oldMember) && !isAdmin(newMember):
+		return "demoted"
+	case newMember.Status == "restricted":
+		return "restricted"
+	}
+	return "changed"
+}
+
+// handleChatMember calls bot.onChatMember, or bot.onMyChatMember if status of the bot itself is changed, with the update and the event name
+func (a *application) handleChatMember(handler string, cmu *ChatMemberUpdated) {
+	if !a.hasHandler(handler) {
+		return
+	}
+
+	if _, err := a.callHandler(cmu.Chat.ID, handler, cmu, memberEvent(cmu)); err != nil {
+		log.Error("Error in handleChatMember ", err)
+	}
+}
+
+// handleJoinRequest calls bot.onJoinRequest bound to the private chat of the user, bot may message the user until the request is approved or declined
+func (a *application) handleJoinRequest(cjr *ChatJoinRequest) {
+	if !a.hasHandler("onJoinRequest") {
+		return
+	}
+
+	if _, err := a.callHandler(strconv.Itoa(cjr.From.ID), "onJoinRequest", cjr); err != nil {
+		log.Error("Error in handleJoinRequest ", err)
+	}
+}
+
+func (a *application) getApproveJoinRequestFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		chatID, memberID, ok := memberArguments(call, "")
+		if !ok {
+			return otto.FalseValue()
+		}
+
+		return adminResult("approving join request", a.tgClient.ApproveJoin(chatID, memberID))
+	}
+}
+
+func (a *application) getDeclineJoinRequestFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		chatID, memberID, ok := memberArguments(call, "")
+		if !ok {
+			return otto.FalseValue()
+		}
+
+		return adminResult("declining join request", a.tgClient.DeclineJoin(chatID, memberID))
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yanzay/tbot/v2"
+)
+
+func TestMemberEvent(t *testing.T) {
+	member := func(status string, isMember bool) *tbot.ChatMember {
+		return &tbot.ChatMember{Status: status, IsMember: isMember}
+	}
+	tests := []struct {
+		chatType string
+		old      *tbot.ChatMember
+		new      *tbot.ChatMember
+		event    string
+	}{
+		{"supergroup", member("left", false), member("member", false), "joined"},
+		{"supergroup", member("left", false), member("restricted", true), "joined"},
+		{"supergroup", member("member", false), member("left", false), "left"},
+		{"supergroup", member("member", false), member("kicked", false), "banned"},
+		{"supergroup", member("kicked", false), member("left", false), "unbanned"},
+		{"supergroup", member("member", false), member("administrator", false), "promoted"},
+		{"supergroup", member("administrator", false), member("member", false), "demoted"},
+		{"supergroup", member("member", false), member("restricted", true), "restricted"},
+		{"supergroup", member("restricted", true), member("restricted", false), "left"},
+		{"private", member("member", false), member("kicked", false), "blocked"},
+		{"private", member("kicked", false), member("member", false), "unblocked"},
+		{"supergroup", nil, member("member", false), "changed"},
+	}
+
+	for _, test := range tests {
+		cmu := &ChatMemberUpdated{Chat: tbot.Chat{Type: test.chatType}, OldChatMember: test.old, NewChatMember: test.new}
+		assert.Equal(t, test.event, memberEvent(cmu))
+	}
+}
+
+func TestHandleChatMemberUpdates(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	vm := VmFactoryImpl{}.GetVm()
+	a := &application{tgClient: telebot, vmTemplate: vm}
+	vm.Set("send", a.getSendFunc(""))
+	vm.Set("approveJoinRequest", a.getApproveJoinRequestFunc())
+	vm.Set("declineJoinRequest", a.getDeclineJoinRequestFunc())
+	vm.Run(`bot = {
+		onChatMember: function (update, event) {
+			if (event == "joined") {
+				send("Welcome, " + update.NewChatMember.User.FirstName)
+			}
+		},
+		onMyChatMember: function (update, event) {
+			console.log("Bot is " + event + " in " + update.Chat.ID)
+		},
+		onJoinRequest: function (request) {
+			if (request.Bio == "spam") {
+				declineJoinRequest(request.Chat.ID, request.From.ID)
+			} else {
+				approveJoinRequest(request.Chat.ID, request.From.ID)
+				send("Your request to join " + request.Chat.Title + " is approved")
+			}
+		}
+	}`)
+
+	telebot.On("SendText", "-100123", "Welcome, John", mock.AnythingOfType("func(url.Values)")).Return(1, nil)
+	telebot.On("ApproveJoin", "-100123", 42).Return(nil)
+	telebot.On("DeclineJoin", "-100123", 43).Return(nil)
+	telebot.On("SendText", "42", "Your request to join Bot lovers is approved", mock.AnythingOfType("func(url.Values)")).Return(2, nil)
+
+	for _, data := range []string{
+		`{"update_id":1,"chat_member":{"chat":{"id":-100123,"type":"supergroup"},"from":{"id":42},"date":1,
+			"old_chat_member":{"user":{"id":42,"first_name":"John"},"status":"left"},
+			"new_chat_member":{"user":{"id":42,"first_name":"John"},"status":"member"}}}`,
+		`{"update_id":2,"my_chat_member":{"chat":{"id":42,"type":"private"},"from":{"id":42},"date":1,
+			"old_chat_member":{"user":{"id":1,"is_bot":true},"status":"member"},
+			"new_chat_member":{"user":{"id":1,"is_bot":true},"status":"kicked"}}}`,
+		`{"update_id":3,"chat_join_request":{"chat":{"id":-100123,"type":"supergroup","title":"Bot lovers"},"from":{"id":42},"date":1}}`,
+		`{"update_id":4,"chat_join_request":{"chat":{"id":-100123,"type":"supergroup","title":"Bot lovers"},"from":{"id":43},"date":1,"bio":"spam"}}`,
+	} {
+		update := &Update{}
+		assert.Nil(t, json.Unmarshal([]byte(data), update))
+		a.handleUpdate(update)
+	}
+
+	telebot.AssertExpectations(t)
+}
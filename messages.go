@@ -0,0 +1,196 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+	"github.com/yanzay/tbot/v2"
+)
+
+func (a *application) sendLocation(chatID string, latitude float64, longitude float64, livePeriod int) int {
+	id, err := a.tgClient.ShareLocation(chatID, latitude, longitude, livePeriod)
+	if err != nil {
+		log.Error("Error sending location ", err)
+	}
+	return id
+}
+
+func (a *application) editLiveLocation(chatID string, msgID int, latitude float64, longitude float64) {
+	err := a.tgClient.EditLiveLocation(chatID, msgID, latitude, longitude)
+	if err != nil {
+		log.Error("Error editing live location ", err)
+	}
+}
+
+func (a *application) stopLiveLocation(chatID string, msgID int) {
+	err := a.tgClient.StopLiveLocation(chatID, msgID)
+	if err != nil {
+		log.Error("Error stopping live location ", err)
+	}
+}
+
+func (a *application) sendVenue(chatID string, latitude float64, longitude float64, title string, address string) int {
+	id, err := a.tgClient.ShareVenue(chatID, latitude, longitude, title, address)
+	if err != nil {
+		log.Error("Error sending venue ", err)
+	}
+	return id
+}
+
+func (a *application) sendContact(chatID string, phoneNumber string, firstName string, lastName string) int {
+	id, err := a.tgClient.ShareContact(chatID, phoneNumber, firstName, lastName)
+	if err != nil {
+		log.Error("Error sending contact ", err)
+	}
+	return id
+}
+
+func (a *application) sendDice(chatID string, emoji string) (int, int) {
+	id, value, err := a.tgClient.RollDice(chatID, emoji)
+	if err != nil {
+		log.Error("Error sending dice ", err)
+	}
+	return id, value
+}
+
+// sendAnimation uploads animation from attachments directory or forwards it by file id
+func (a *application) sendAnimation(chatID string, animation string, caption string) int {
+	var id int
+	var err error
+
+	attachmentFile := filepath.Join(a.attachmentsDir, animation)
+	if FileExists(attachmentFile) {
+		id, err = a.tgClient.AttachAnimation(chatID, attachmentFile, caption, tbot.OptReplyKeyboardRemove)
+	} else {
+		id, err = a.tgClient.ForwardAnimation(chatID, animation, caption, tbot.OptReplyKeyboardRemove)
+	}
+	if err != nil {
+		log.Error("Error sending animation ", err)
+	}
+
+	return id
+}
+
+func (a *application) getSendLocationFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		result := otto.Value{}
+
+		latitude, err := call.Argument(0).ToFloat()
+		if err != nil {
+			return result
+		}
+		longitude, err := call.Argument(1).ToFloat()
+		if err != nil {
+			return result
+		}
+		var livePeriod int64
+		if call.Argument(2).IsNumber() {
+			livePeriod, _ = call.Argument(2).ToInteger()
+		}
+
+		result, _ = otto.ToValue(a.sendLocation(chatIDArgument(call.Argument(3), userID), latitude, longitude, int(livePeriod)))
+
+		return result
+	}
+}
+
+func (a *application) getEditLiveLocationFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		if chatID, err := call.Argument(0).ToString(); err == nil {
+			if msgID, err := call.Argument(1).ToInteger(); err == nil {
+				if latitude, err := call.Argument(2).ToFloat(); err == nil {
+					if longitude, err := call.Argument(3).ToFloat(); err == nil {
+						a.editLiveLocation(chatID, int(msgID), latitude, longitude)
+					}
+				}
+			}
+		}
+
+		return otto.Value{}
+	}
+}
+
+func (a *application) getStopLiveLocationFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		if chatID, err := call.Argument(0).ToString(); err == nil {
+			if msgID, err := call.Argument(1).ToInteger(); err == nil {
+				a.stopLiveLocation(chatID, int(msgID))
+			}
+		}
+
+		return otto.Value{}
+	}
+}
+
+func (a *application) getSendVenueFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		result := otto.Value{}
+
+		latitude, err := call.Argument(0).ToFloat()
+		if err != nil {
+			return result
+		}
+		longitude, err := call.Argument(1).ToFloat()
+		if err != nil {
+			return result
+		}
+		title, _ := call.Argument(2).ToString()
+		address, _ := call.Argument(3).ToString()
+
+		result, _ = otto.ToValue(a.sendVenue(chatIDArgument(call.Argument(4), userID), latitude, longitude, title, address))
+
+		return result
+	}
+}
+
+func (a *application) getSendContactFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		var phoneNumber, firstName, lastName string
+
+		if call.Argument(0).IsDefined() {
+			phoneNumber, _ = call.Argument(0).ToString()
+		}
+		if call.Argument(1).IsString() {
+			firstName = call.Argument(1).String()
+		}
+		if call.Argument(2).IsString() {
+			lastName = call.Argument(2).String()
+		}
+
+		result, _ := otto.ToValue(a.sendContact(chatIDArgument(call.Argument(3), userID), phoneNumber, firstName, lastName))
+
+		return result
+	}
+}
+
+func (a *application) getSendDiceFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		var emoji string
+		if call.Argument(0).IsString() {
+			emoji = call.Argument(0).String()
+		}
+
+		id, value := a.sendDice(chatIDArgument(call.Argument(1), userID), emoji)
+
+		return toJsValue(call.Otto, map[string]int{"id": id, "value": value})
+	}
+}
+
+func (a *application) getSendAnimationFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		var animation, caption string
+
+		if call.Argument(0).IsString() {
+			animation = strings.TrimSpace(call.Argument(0).String())
+		}
+		if call.Argument(1).IsString() {
+			caption = call.Argument(1).String()
+		}
+
+		result, _ := otto.ToValue(a.sendAnimation(chatIDArgument(call.Argument(2), userID), animation, caption))
+
+		return result
+	}
+}
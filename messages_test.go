@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSendMessageTypes(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, attachmentsDir: attachmentsDir}
+
+	vm := otto.New()
+	vm.Set("sendLocation", a.getSendLocationFunc(userID))
+	vm.Set("editLiveLocation", a.getEditLiveLocationFunc())
+	vm.Set("stopLiveLocation", a.getStopLiveLocationFunc())
+	vm.Set("sendVenue", a.getSendVenueFunc(userID))
+	vm.Set("sendContact", a.getSendContactFunc(userID))
+	vm.Set("sendDice", a.getSendDiceFunc(userID))
+	vm.Set("sendAnimation", a.getSendAnimationFunc(userID))
+
+	telebot.On("ShareLocation", userID, 42.87, 74.59, 600).Return(1, nil)
+	telebot.On("EditLiveLocation", userID, 1, 42.88, 74.6).Return(nil)
+	telebot.On("StopLiveLocation", userID, 1).Return(nil)
+	telebot.On("ShareVenue", "456", 42.87, 74.59, "Office", "Main street 1").Return(2, nil)
+	telebot.On("ShareContact", userID, "+996555000000", "John", "").Return(3, nil)
+	telebot.On("RollDice", userID, "🎯").Return(4, 6, nil)
+	telebot.On("AttachAnimation", userID, filepath.Join(attachmentsDir, "puppy.mp4"), "Puppy", mock.AnythingOfType("func(url.Values)")).Return(5, nil)
+	telebot.On("ForwardAnimation", "456", "animationID", "", mock.AnythingOfType("func(url.Values)")).Return(6, nil)
+
+	val, err := vm.Run(`
+		var ids = []
+		var id = sendLocation(42.87, 74.59, 600)
+		editLiveLocation("` + userID + `", id, 42.88, 74.6)
+		stopLiveLocation("` + userID + `", id)
+		ids.push(id)
+		ids.push(sendVenue(42.87, 74.59, "Office", "Main street 1", "456"))
+		ids.push(sendContact("+996555000000", "John"))
+		var dice = sendDice("🎯")
+		ids.push(dice.id, dice.value)
+		ids.push(sendAnimation("puppy.mp4", "Puppy"))
+		ids.push(sendAnimation("animationID", null, 456))
+		ids.join(",")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1,2,3,4,6,5,6", val.String())
+	telebot.AssertExpectations(t)
+}
+
+func TestTbotWrapperRollDice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bottoken/sendDice", r.URL.Path)
+		r.ParseForm()
+		assert.Equal(t, "🎲", r.PostForm.Get("emoji"))
+
+		rw.Write([]byte(`{"ok":true,"result":{"message_id":7,"dice":{"emoji":"🎲","value":3}}}`))
+	}))
+	defer server.Close()
+
+	defaultURL := apiBaseURL
+	apiBaseURL = server.URL
+	defer func() { apiBaseURL = defaultURL }()
+
+	wrapper := &TbotWrapper{token: "token"}
+	id, value, err := wrapper.RollDice(userID, "🎲")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 7, id)
+	assert.Equal(t, 3, value)
+}
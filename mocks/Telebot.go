@@ -25,6 +25,69 @@ func (_m *Telebot) AnswerCallback(callbackQueryID string) error {
 	return r0
 }
 
+// AnswerInline provides a mock function with given fields: inlineQueryID, results, option
+func (_m *Telebot) AnswerInline(inlineQueryID string, results string, option func(url.Values)) error {
+	ret := _m.Called(inlineQueryID, results, option)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, func(url.Values)) error); ok {
+		r0 = rf(inlineQueryID, results, option)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AnswerPreCheckout provides a mock function with given fields: preCheckoutQueryID, ok, errorMessage
+func (_m *Telebot) AnswerPreCheckout(preCheckoutQueryID string, ok bool, errorMessage string) error {
+	ret := _m.Called(preCheckoutQueryID, ok, errorMessage)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, bool, string) error); ok {
+		r0 = rf(preCheckoutQueryID, ok, errorMessage)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ApproveJoin provides a mock function with given fields: chatID, userID
+func (_m *Telebot) ApproveJoin(chatID string, userID int) error {
+	ret := _m.Called(chatID, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int) error); ok {
+		r0 = rf(chatID, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AttachAnimation provides a mock function with given fields: chatID, filename, text, option
+func (_m *Telebot) AttachAnimation(chatID string, filename string, text string, option func(url.Values)) (int, error) {
+	ret := _m.Called(chatID, filename, text, option)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string, string, string, func(url.Values)) int); ok {
+		r0 = rf(chatID, filename, text, option)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, string, func(url.Values)) error); ok {
+		r1 = rf(chatID, filename, text, option)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // AttachAudio provides a mock function with given fields: chatID, filename, text, option
 func (_m *Telebot) AttachAudio(chatID string, filename string, text string, option func(url.Values)) (int, error) {
 	ret := _m.Called(chatID, filename, text, option)
@@ -46,6 +109,27 @@ func (_m *Telebot) AttachAudio(chatID string, filename string, text string, opti
 	return r0, r1
 }
 
+// AttachContent provides a mock function with given fields: chatID, fileType, name, content, text, option
+func (_m *Telebot) AttachContent(chatID string, fileType string, name string, content []byte, text string, option func(url.Values)) (int, error) {
+	ret := _m.Called(chatID, fileType, name, content, text, option)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string, string, string, []byte, string, func(url.Values)) int); ok {
+		r0 = rf(chatID, fileType, name, content, text, option)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, string, []byte, string, func(url.Values)) error); ok {
+		r1 = rf(chatID, fileType, name, content, text, option)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // AttachFile provides a mock function with given fields: chatID, filename, text, option
 func (_m *Telebot) AttachFile(chatID string, filename string, text string, option func(url.Values)) (int, error) {
 	ret := _m.Called(chatID, filename, text, option)
@@ -109,6 +193,169 @@ func (_m *Telebot) AttachVideo(chatID string, filename string, text string, opti
 	return r0, r1
 }
 
+// BanMember provides a mock function with given fields: chatID, userID, untilDate
+func (_m *Telebot) BanMember(chatID string, userID int, untilDate int64) error {
+	ret := _m.Called(chatID, userID, untilDate)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int, int64) error); ok {
+		r0 = rf(chatID, userID, untilDate)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ClosePoll provides a mock function with given fields: chatID, messageID
+func (_m *Telebot) ClosePoll(chatID string, messageID int) (string, error) {
+	ret := _m.Called(chatID, messageID)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, int) string); ok {
+		r0 = rf(chatID, messageID)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(chatID, messageID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountMembers provides a mock function with given fields: chatID
+func (_m *Telebot) CountMembers(chatID string) (int, error) {
+	ret := _m.Called(chatID)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string) int); ok {
+		r0 = rf(chatID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(chatID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateInviteLink provides a mock function with given fields: chatID, option
+func (_m *Telebot) CreateInviteLink(chatID string, option func(url.Values)) (string, error) {
+	ret := _m.Called(chatID, option)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, func(url.Values)) string); ok {
+		r0 = rf(chatID, option)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, func(url.Values)) error); ok {
+		r1 = rf(chatID, option)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateInvoice provides a mock function with given fields: chatID, payload, providerToken, invoice, prices, option
+func (_m *Telebot) CreateInvoice(chatID string, payload string, providerToken string, invoice *tbot.Invoice, prices []tbot.LabeledPrice, option func(url.Values)) (int, error) {
+	ret := _m.Called(chatID, payload, providerToken, invoice, prices, option)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string, string, string, *tbot.Invoice, []tbot.LabeledPrice, func(url.Values)) int); ok {
+		r0 = rf(chatID, payload, providerToken, invoice, prices, option)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, string, *tbot.Invoice, []tbot.LabeledPrice, func(url.Values)) error); ok {
+		r1 = rf(chatID, payload, providerToken, invoice, prices, option)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreatePoll provides a mock function with given fields: chatID, question, options, option
+func (_m *Telebot) CreatePoll(chatID string, question string, options []string, option func(url.Values)) (int, string, error) {
+	ret := _m.Called(chatID, question, options, option)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string, string, []string, func(url.Values)) int); ok {
+		r0 = rf(chatID, question, options, option)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(string, string, []string, func(url.Values)) string); ok {
+		r1 = rf(chatID, question, options, option)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(string, string, []string, func(url.Values)) error); ok {
+		r2 = rf(chatID, question, options, option)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// DeclineJoin provides a mock function with given fields: chatID, userID
+func (_m *Telebot) DeclineJoin(chatID string, userID int) error {
+	ret := _m.Called(chatID, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int) error); ok {
+		r0 = rf(chatID, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DefaultPermissions provides a mock function with given fields: chatID
+func (_m *Telebot) DefaultPermissions(chatID string) (*tbot.ChatPermissions, error) {
+	ret := _m.Called(chatID)
+
+	var r0 *tbot.ChatPermissions
+	if rf, ok := ret.Get(0).(func(string) *tbot.ChatPermissions); ok {
+		r0 = rf(chatID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*tbot.ChatPermissions)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(chatID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteMsg provides a mock function with given fields: chatID, messageID
 func (_m *Telebot) DeleteMsg(chatID string, messageID int) error {
 	ret := _m.Called(chatID, messageID)
@@ -123,20 +370,20 @@ func (_m *Telebot) DeleteMsg(chatID string, messageID int) error {
 	return r0
 }
 
-// EditInlineMarkup provides a mock function with given fields: chatID, messageID, markup
-func (_m *Telebot) EditInlineMarkup(chatID string, messageID int, markup *tbot.InlineKeyboardMarkup) (int, error) {
-	ret := _m.Called(chatID, messageID, markup)
+// EditInlineMarkup provides a mock function with given fields: chatID, messageID, option
+func (_m *Telebot) EditInlineMarkup(chatID string, messageID int, option func(url.Values)) (int, error) {
+	ret := _m.Called(chatID, messageID, option)
 
 	var r0 int
-	if rf, ok := ret.Get(0).(func(string, int, *tbot.InlineKeyboardMarkup) int); ok {
-		r0 = rf(chatID, messageID, markup)
+	if rf, ok := ret.Get(0).(func(string, int, func(url.Values)) int); ok {
+		r0 = rf(chatID, messageID, option)
 	} else {
 		r0 = ret.Get(0).(int)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, int, *tbot.InlineKeyboardMarkup) error); ok {
-		r1 = rf(chatID, messageID, markup)
+	if rf, ok := ret.Get(1).(func(string, int, func(url.Values)) error); ok {
+		r1 = rf(chatID, messageID, option)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -144,13 +391,13 @@ func (_m *Telebot) EditInlineMarkup(chatID string, messageID int, markup *tbot.I
 	return r0, r1
 }
 
-// EditMsg provides a mock function with given fields: chatID, messageID, text, markup
-func (_m *Telebot) EditMsg(chatID string, messageID int, text string, markup *tbot.InlineKeyboardMarkup) error {
-	ret := _m.Called(chatID, messageID, text, markup)
+// EditLiveLocation provides a mock function with given fields: chatID, messageID, latitude, longitude
+func (_m *Telebot) EditLiveLocation(chatID string, messageID int, latitude float64, longitude float64) error {
+	ret := _m.Called(chatID, messageID, latitude, longitude)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, int, string, *tbot.InlineKeyboardMarkup) error); ok {
-		r0 = rf(chatID, messageID, text, markup)
+	if rf, ok := ret.Get(0).(func(string, int, float64, float64) error); ok {
+		r0 = rf(chatID, messageID, latitude, longitude)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -158,6 +405,41 @@ func (_m *Telebot) EditMsg(chatID string, messageID int, text string, markup *tb
 	return r0
 }
 
+// EditMsg provides a mock function with given fields: chatID, messageID, text, option
+func (_m *Telebot) EditMsg(chatID string, messageID int, text string, option func(url.Values)) error {
+	ret := _m.Called(chatID, messageID, text, option)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int, string, func(url.Values)) error); ok {
+		r0 = rf(chatID, messageID, text, option)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ForwardAnimation provides a mock function with given fields: chatID, fileID, text, option
+func (_m *Telebot) ForwardAnimation(chatID string, fileID string, text string, option func(url.Values)) (int, error) {
+	ret := _m.Called(chatID, fileID, text, option)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string, string, string, func(url.Values)) int); ok {
+		r0 = rf(chatID, fileID, text, option)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, string, func(url.Values)) error); ok {
+		r1 = rf(chatID, fileID, text, option)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ForwardAudio provides a mock function with given fields: chatID, fileID, text, option
 func (_m *Telebot) ForwardAudio(chatID string, fileID string, text string, option func(url.Values)) (int, error) {
 	ret := _m.Called(chatID, fileID, text, option)
@@ -242,6 +524,29 @@ func (_m *Telebot) ForwardVideo(chatID string, fileID string, text string, optio
 	return r0, r1
 }
 
+// GetAdmins provides a mock function with given fields: chatID
+func (_m *Telebot) GetAdmins(chatID string) ([]*tbot.ChatMember, error) {
+	ret := _m.Called(chatID)
+
+	var r0 []*tbot.ChatMember
+	if rf, ok := ret.Get(0).(func(string) []*tbot.ChatMember); ok {
+		r0 = rf(chatID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*tbot.ChatMember)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(chatID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetFileInfo provides a mock function with given fields: fileID
 func (_m *Telebot) GetFileInfo(fileID string) (*tbot.File, error) {
 	ret := _m.Called(fileID)
@@ -265,6 +570,113 @@ func (_m *Telebot) GetFileInfo(fileID string) (*tbot.File, error) {
 	return r0, r1
 }
 
+// PinMsg provides a mock function with given fields: chatID, messageID, silent
+func (_m *Telebot) PinMsg(chatID string, messageID int, silent bool) error {
+	ret := _m.Called(chatID, messageID, silent)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int, bool) error); ok {
+		r0 = rf(chatID, messageID, silent)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PromoteMember provides a mock function with given fields: chatID, userID, option
+func (_m *Telebot) PromoteMember(chatID string, userID int, option func(url.Values)) error {
+	ret := _m.Called(chatID, userID, option)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int, func(url.Values)) error); ok {
+		r0 = rf(chatID, userID, option)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RestrictMember provides a mock function with given fields: chatID, userID, permissions, untilDate
+func (_m *Telebot) RestrictMember(chatID string, userID int, permissions *tbot.ChatPermissions, untilDate int64) error {
+	ret := _m.Called(chatID, userID, permissions, untilDate)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int, *tbot.ChatPermissions, int64) error); ok {
+		r0 = rf(chatID, userID, permissions, untilDate)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RollDice provides a mock function with given fields: chatID, emoji
+func (_m *Telebot) RollDice(chatID string, emoji string) (int, int, error) {
+	ret := _m.Called(chatID, emoji)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string, string) int); ok {
+		r0 = rf(chatID, emoji)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(string, string) int); ok {
+		r1 = rf(chatID, emoji)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(string, string) error); ok {
+		r2 = rf(chatID, emoji)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SendAction provides a mock function with given fields: chatID, action
+func (_m *Telebot) SendAction(chatID string, action string) error {
+	ret := _m.Called(chatID, action)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(chatID, action)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SendAlbum provides a mock function with given fields: chatID, media, files
+func (_m *Telebot) SendAlbum(chatID string, media string, files map[string]string) ([]int, error) {
+	ret := _m.Called(chatID, media, files)
+
+	var r0 []int
+	if rf, ok := ret.Get(0).(func(string, string, map[string]string) []int); ok {
+		r0 = rf(chatID, media, files)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, map[string]string) error); ok {
+		r1 = rf(chatID, media, files)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SendText provides a mock function with given fields: chatID, text, option
 func (_m *Telebot) SendText(chatID string, text string, option func(url.Values)) (int, error) {
 	ret := _m.Called(chatID, text, option)
@@ -285,3 +697,150 @@ func (_m *Telebot) SendText(chatID string, text string, option func(url.Values))
 
 	return r0, r1
 }
+
+// SetDescription provides a mock function with given fields: chatID, description
+func (_m *Telebot) SetDescription(chatID string, description string) error {
+	ret := _m.Called(chatID, description)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(chatID, description)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPhoto provides a mock function with given fields: chatID, filename
+func (_m *Telebot) SetPhoto(chatID string, filename string) error {
+	ret := _m.Called(chatID, filename)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(chatID, filename)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetTitle provides a mock function with given fields: chatID, title
+func (_m *Telebot) SetTitle(chatID string, title string) error {
+	ret := _m.Called(chatID, title)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(chatID, title)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ShareContact provides a mock function with given fields: chatID, phoneNumber, firstName, lastName
+func (_m *Telebot) ShareContact(chatID string, phoneNumber string, firstName string, lastName string) (int, error) {
+	ret := _m.Called(chatID, phoneNumber, firstName, lastName)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string, string, string, string) int); ok {
+		r0 = rf(chatID, phoneNumber, firstName, lastName)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, string, string) error); ok {
+		r1 = rf(chatID, phoneNumber, firstName, lastName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ShareLocation provides a mock function with given fields: chatID, latitude, longitude, livePeriod
+func (_m *Telebot) ShareLocation(chatID string, latitude float64, longitude float64, livePeriod int) (int, error) {
+	ret := _m.Called(chatID, latitude, longitude, livePeriod)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string, float64, float64, int) int); ok {
+		r0 = rf(chatID, latitude, longitude, livePeriod)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, float64, float64, int) error); ok {
+		r1 = rf(chatID, latitude, longitude, livePeriod)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ShareVenue provides a mock function with given fields: chatID, latitude, longitude, title, address
+func (_m *Telebot) ShareVenue(chatID string, latitude float64, longitude float64, title string, address string) (int, error) {
+	ret := _m.Called(chatID, latitude, longitude, title, address)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string, float64, float64, string, string) int); ok {
+		r0 = rf(chatID, latitude, longitude, title, address)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, float64, float64, string, string) error); ok {
+		r1 = rf(chatID, latitude, longitude, title, address)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StopLiveLocation provides a mock function with given fields: chatID, messageID
+func (_m *Telebot) StopLiveLocation(chatID string, messageID int) error {
+	ret := _m.Called(chatID, messageID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int) error); ok {
+		r0 = rf(chatID, messageID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnbanMember provides a mock function with given fields: chatID, userID
+func (_m *Telebot) UnbanMember(chatID string, userID int) error {
+	ret := _m.Called(chatID, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int) error); ok {
+		r0 = rf(chatID, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnpinMsg provides a mock function with given fields: chatID, messageID
+func (_m *Telebot) UnpinMsg(chatID string, messageID int) error {
+	ret := _m.Called(chatID, messageID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int) error); ok {
+		r0 = rf(chatID, messageID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
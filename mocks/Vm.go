@@ -34,6 +34,29 @@ func (_m *Vm) Call(source string, argumentList ...interface{}) (otto.Value, erro
 	return r0, r1
 }
 
+// Compile provides a mock function with given fields: filename, src
+func (_m *Vm) Compile(filename string, src interface{}) (*otto.Script, error) {
+	ret := _m.Called(filename, src)
+
+	var r0 *otto.Script
+	if rf, ok := ret.Get(0).(func(string, interface{}) *otto.Script); ok {
+		r0 = rf(filename, src)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*otto.Script)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, interface{}) error); ok {
+		r1 = rf(filename, src)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Copy provides a mock function with given fields:
 func (_m *Vm) Copy() Vm {
 	ret := _m.Called()
@@ -50,6 +73,11 @@ func (_m *Vm) Copy() Vm {
 	return r0
 }
 
+// Interrupt provides a mock function with given fields: f
+func (_m *Vm) Interrupt(f func()) {
+	_m.Called(f)
+}
+
 // Object provides a mock function with given fields: source
 func (_m *Vm) Object(source string) (*otto.Object, error) {
 	ret := _m.Called(source)
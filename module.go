@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+// wrapper keeps module source on the first line, so that line numbers in errors match the file
+const moduleWrapperHead = "(function (exports, require, module, __filename, __dirname) {"
+const moduleWrapperTail = "\n})"
+
+// scriptError converts js error to an error carrying file name and line of the failure
+func scriptError(err error) error {
+	if e, ok := err.(*otto.Error); ok {
+		return errors.New(strings.TrimSpace(e.String()))
+	}
+
+	return err
+}
+
+// resolveModule returns absolute path of module required from file "from", empty "from" means scripts root
+func resolveModule(from string, name string) (string, error) {
+	dir := GetEnv("SCRIPTS_ROOT", "scripts")
+	if from != "" && (strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../")) {
+		dir = filepath.Dir(from)
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, name)
+	}
+	if !FileExists(path) && filepath.Ext(path) != ".js" {
+		path += ".js"
+	}
+
+	return filepath.Abs(path)
+}
+
+func (a *application) getRequireFunc(from string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		name, err := call.Argument(0).ToString()
+		if err != nil || !call.Argument(0).IsString() {
+			panic(call.Otto.MakeTypeError("require expects module name"))
+		}
+
+		path, err := resolveModule(from, name)
+		if err != nil {
+			panic(call.Otto.MakeCustomError("RequireError", err.Error()))
+		}
+
+		exports, err := a.requireModule(call.Otto, from, path)
+		if err != nil {
+			panic(call.Otto.MakeCustomError("RequireError", err.Error()))
+		}
+
+		return exports
+	}
+}
+
+// requireModule evaluates module once per runtime and returns its exports, modules are cached in require.cache
+func (a *application) requireModule(vm *otto.Otto, from string, path string) (otto.Value, error) {
+	cache, err := vm.Object("require.cache")
+	if err != nil {
+		return otto.Value{}, err
+	}
+
+	if cached, _ := cache.Get(path); cached.IsObject() {
+		module := cached.Object()
+		if loaded, _ := module.Get("loaded"); !isTrue(loaded) {
+			return otto.Value{}, fmt.Errorf("Cyclic require of %s from %s", path, from)
+		}
+		return module.Get("exports")
+	}
+
+	src, err := ReadFile(path)
+	if err != nil {
+		return otto.Value{}, err
+	}
+
+	script, err := vm.Compile(path, moduleWrapperHead+src+moduleWrapperTail)
+	if err != nil {
+		return otto.Value{}, err
+	}
+
+	fn, err := vm.Run(script)
+	if err != nil {
+		return otto.Value{}, scriptError(err)
+	}
+
+	module, err := vm.Object(`({exports: {}, loaded: false})`)
+	if err != nil {
+		return otto.Value{}, err
+	}
+	module.Set("id", path)
+	cache.Set(path, module)
+
+	exports, _ := module.Get("exports")
+	if _, err := fn.Call(otto.NullValue(), exports, a.getRequireFunc(path), module, path, filepath.Dir(path)); err != nil {
+		cache.Set(path, otto.UndefinedValue())
+		return otto.Value{}, scriptError(err)
+	}
+	module.Set("loaded", true)
+
+	return module.Get("exports")
+}
+
+// requiredModules lists files loaded by require in the vm
+func requiredModules(vm Vm) []string {
+	keys, err := vm.Run(`Object.keys(require.cache)`)
+	if err != nil {
+		return nil
+	}
+
+	exported, _ := keys.Export()
+	modules, _ := exported.([]string)
+
+	return modules
+}
+
+func isTrue(val otto.Value) bool {
+	b, _ := val.ToBoolean()
+	return b
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequire(t *testing.T) {
+	dir, err := ioutil.TempDir("", "module_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "lib"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "lib", "math.js"), []byte(`
+var counter = require("./counter")
+exports.add = function (a, b) { counter.calls++; return a + b }`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "lib", "counter.js"), []byte(`module.exports = { calls: 0 }`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "a.js"), []byte(`require("./b")`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.js"), []byte(`require("./a")`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "broken.js"), []byte("var x = 1\nundefinedFunction()"), 0644)
+
+	os.Setenv("SCRIPTS_ROOT", dir)
+	defer os.Unsetenv("SCRIPTS_ROOT")
+
+	a := &application{vmFactory: VmFactoryImpl{}}
+	vm := a.createVmTemplate()
+
+	//exports are cached
+	val, err := vm.Run(`var m = require("lib/math"); m.add(1, 2); require("lib/math.js").add(2, 3) + require("lib/counter").calls * 100`)
+	assert.Nil(t, err)
+	res, _ := val.ToInteger()
+	assert.Equal(t, int64(205), res)
+
+	//loaded modules are reported for watching
+	assert.Equal(t, 2, len(requiredModules(vm)))
+
+	//cyclic dependency
+	_, err = vm.Run(`require("a")`)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Cyclic require")
+
+	//errors contain file name and line
+	_, err = vm.Run(`require("broken")`)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "broken.js:2")
+
+	//missing module
+	_, err = vm.Run(`require("missing")`)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+	"github.com/yanzay/tbot/v2"
+)
+
+// message shown to user when bot.onPreCheckout rejects payment without a reason
+const defaultPaymentError = "Payment can not be processed, please try again later"
+
+// Payment is a successful payment passed to bot.onPayment, payload of invoice is decoded if it was sent as an object
+type Payment struct {
+	Currency                string
+	TotalAmount             int
+	Payload                 interface{}
+	ShippingOptionID        string
+	OrderInfo               *tbot.OrderInfo
+	TelegramPaymentChargeID string
+	ProviderPaymentChargeID string
+}
+
+// decodePayload returns invoice payload as object if it holds json, otherwise as is
+func decodePayload(payload string) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(payload), &decoded); err != nil {
+		return payload
+	}
+	if _, ok := decoded.(map[string]interface{}); !ok {
+		return payload
+	}
+	return decoded
+}
+
+// invoiceSettings converts optional fields of invoice object to request parameters
+func invoiceSettings(invoice map[string]interface{}) func(url.Values) {
+	return func(r url.Values) {
+		for _, key := range []string{"photo_url", "photo_width", "photo_height", "provider_data", "max_tip_amount"} {
+			if val, ok := stringField(invoice, key); ok {
+				r.Set(key, val)
+			}
+		}
+		for _, key := range []string{"need_name", "need_phone_number", "need_email", "need_shipping_address", "send_phone_number_to_provider", "send_email_to_provider", "is_flexible"} {
+			if boolField(invoice, key) {
+				r.Set(key, "true")
+			}
+		}
+		if options, ok := invoice["options"]; ok {
+			optReplyMarkup(buildInlineOptions(options))(r)
+		}
+	}
+}
+
+// sendInvoice sends invoice described by object with title, description, payload, currency and prices, returns message id
+func (a *application) sendInvoice(chatID string, invoice map[string]interface{}) int {
+	title, _ := stringField(invoice, "title")
+	description, _ := stringField(invoice, "description")
+	currency, _ := stringField(invoice, "currency")
+	startParameter, _ := stringField(invoice, "start_parameter")
+	providerToken, ok := stringField(invoice, "provider_token")
+	if !ok {
+		providerToken = GetEnv("PAYMENT_PROVIDER_TOKEN", "")
+	}
+
+	payload, _ := stringField(invoice, "payload")
+	if _, ok := invoice["payload"].(map[string]interface{}); ok {
+		data, _ := json.Marshal(invoice["payload"])
+		payload = string(data)
+	}
+
+	var prices []tbot.LabeledPrice
+	list, _ := toSlice(invoice["prices"])
+	for _, item := range list {
+		if price, ok := item.(map[string]interface{}); ok {
+			label, _ := stringField(price, "label")
+			amount, ok := price["amount"].(float64)
+			if !ok {
+				log.Error("Error sending invoice, invalid price amount ", price["amount"])
+				return 0
+			}
+			prices = append(prices, tbot.LabeledPrice{Label: label, Amount: int(amount)})
+		}
+	}
+
+	id, err := a.tgClient.CreateInvoice(chatID, payload, providerToken, &tbot.Invoice{
+		Title:          title,
+		Description:    description,
+		StartParameter: startParameter,
+		Currency:       currency,
+	}, prices, invoiceSettings(invoice))
+	if err != nil {
+		log.Error("Error sending invoice ", err)
+	}
+
+	return id
+}
+
+// handlePreCheckout answers pre-checkout query with result of bot.onPreCheckout: true approves payment, a string rejects it with the error message.
+// Payments are rejected with generic message when the handler is not defined, so that nothing is sold unchecked,
+// telegram cancels a query which is not answered in 10 seconds
+func (a *application) handlePreCheckout(pcq *tbot.PreCheckoutQuery) {
+	ok, errorMessage := false, defaultPaymentError
+
+	if a.hasHandler("onPreCheckout") {
+		ok, errorMessage = true, ""
+		val, err := a.callHandler(strconv.Itoa(pcq.From.ID), "onPreCheckout", pcq, decodePayload(pcq.InvoicePayload))
+		switch {
+		case err != nil:
+			log.Error("Error in handlePreCheckout ", err)
+			ok, errorMessage = false, defaultPaymentError
+		case val.IsString() && val.String() != "":
+			ok, errorMessage = false, val.String()
+		case val.IsBoolean():
+			if approved, _ := val.ToBoolean(); !approved {
+				ok, errorMessage = false, defaultPaymentError
+			}
+		default:
+			ok, errorMessage = false, defaultPaymentError
+		}
+	}
+
+	if err := a.tgClient.AnswerPreCheckout(pcq.ID, ok, errorMessage); err != nil {
+		log.Error("Error answering pre-checkout query ", err)
+	}
+}
+
+// handlePayment calls bot.onPayment for message about successful payment, returns false if the handler is not defined
+func (a *application) handlePayment(m *tbot.Message) bool {
+	if !a.hasHandler("onPayment") {
+		return false
+	}
+
+	sp := m.SuccessfulPayment
+	payment := &Payment{
+		Currency:                sp.Currency,
+		TotalAmount:             sp.TotalAmount,
+		Payload:                 decodePayload(sp.InvoicePayload),
+		ShippingOptionID:        sp.ShippingOptionID,
+		OrderInfo:               sp.OrderInfo,
+		TelegramPaymentChargeID: sp.TelegramPaymentChargeID,
+		ProviderPaymentChargeID: sp.ProviderPaymentChargeID,
+	}
+
+	if _, err := a.callHandler(m.Chat.ID, "onPayment", payment, m); err != nil {
+		log.Error("Error in handlePayment ", err)
+	}
+
+	return true
+}
+
+func (a *application) getSendInvoiceFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		invoiceInterface, err := exportJSON(call.Otto, call.Argument(0))
+		if err != nil {
+			log.Error("Error reading invoice ", err)
+			return otto.Value{}
+		}
+		invoice, ok := invoiceInterface.(map[string]interface{})
+		if !ok {
+			log.Error("Error sending invoice, invoice object is expected")
+			return otto.Value{}
+		}
+
+		result, _ := otto.ToValue(a.sendInvoice(chatIDArgument(call.Argument(1), userID), invoice))
+
+		return result
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yanzay/tbot/v2"
+)
+
+func TestSendInvoice(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot}
+
+	vm := otto.New()
+	vm.Set("sendInvoice", a.getSendInvoiceFunc(userID))
+
+	request := url.Values{}
+	invoice := &tbot.Invoice{Title: "Premium", Description: "One month of premium", Currency: "USD"}
+	prices := []tbot.LabeledPrice{{Label: "Subscription", Amount: 500}, {Label: "Discount", Amount: -100}}
+	telebot.On("CreateInvoice", userID, `{"plan":"premium"}`, "providerToken", invoice, prices, mock.AnythingOfType("func(url.Values)")).Return(1, nil).Run(func(args mock.Arguments) {
+		args.Get(5).(func(url.Values))(request)
+	})
+
+	val, err := vm.Run(`sendInvoice({
+		title: "Premium",
+		description: "One month of premium",
+		payload: {plan: "premium"},
+		provider_token: "providerToken",
+		currency: "USD",
+		prices: [{label: "Subscription", amount: 500}, {label: "Discount", amount: -100}],
+		photo_url: "https://example.com/premium.png",
+		need_email: true
+	})`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1", val.String())
+	telebot.AssertExpectations(t)
+	assert.Equal(t, url.Values{"photo_url": {"https://example.com/premium.png"}, "need_email": {"true"}}, request)
+}
+
+func TestHandlePreCheckout(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	vm := VmFactoryImpl{}.GetVm()
+	a := &application{tgClient: telebot, vmTemplate: vm}
+	vm.Run(`bot = {
+		onPreCheckout: function (query, payload) {
+			if (payload.plan == "premium") {
+				return true
+			}
+			if (payload == "gift") {
+				return "Gifts are sold out"
+			}
+		}
+	}`)
+
+	telebot.On("AnswerPreCheckout", "q1", true, "").Return(nil)
+	telebot.On("AnswerPreCheckout", "q2", false, "Gifts are sold out").Return(nil)
+	telebot.On("AnswerPreCheckout", "q3", false, defaultPaymentError).Return(nil)
+
+	for _, data := range []string{
+		`{"update_id":1,"pre_checkout_query":{"id":"q1","from":{"id":42},"currency":"USD","total_amount":400,"invoice_payload":"{\"plan\":\"premium\"}"}}`,
+		`{"update_id":2,"pre_checkout_query":{"id":"q2","from":{"id":42},"currency":"USD","total_amount":100,"invoice_payload":"gift"}}`,
+		`{"update_id":3,"pre_checkout_query":{"id":"q3","from":{"id":42},"currency":"USD","total_amount":100,"invoice_payload":"other"}}`,
+	} {
+		update := &Update{}
+		assert.Nil(t, json.Unmarshal([]byte(data), update))
+		a.handleUpdate(update)
+	}
+
+	telebot.AssertExpectations(t)
+}
+
+func TestHandlePreCheckoutWithoutHandler(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	vm := VmFactoryImpl{}.GetVm()
+	a := &application{tgClient: telebot, vmTemplate: vm}
+	vm.Run(`bot = {}`)
+
+	telebot.On("AnswerPreCheckout", "q1", false, defaultPaymentError).Return(nil)
+
+	update := &Update{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"update_id":1,"pre_checkout_query":{"id":"q1","from":{"id":42},"currency":"USD","total_amount":400,"invoice_payload":"premium"}}`), update))
+	a.handleUpdate(update)
+
+	telebot.AssertExpectations(t)
+}
+
+func TestHandlePayment(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	vm := VmFactoryImpl{}.GetVm()
+	a := &application{tgClient: telebot, vmTemplate: vm}
+	vm.Set("send", a.getSendFunc(""))
+	vm.Run(`bot = {
+		onPayment: function (payment, message) {
+			send(payment.Payload.plan + " " + payment.TotalAmount + " " + payment.Currency + " " + payment.TelegramPaymentChargeID)
+		},
+		onMessage: function (message) { send("message") }
+	}`)
+
+	telebot.On("SendText", "42", "premium 400 USD charge1", mock.AnythingOfType("func(url.Values)")).Return(1, nil)
+
+	update := &Update{}
+	json.Unmarshal([]byte(`{"update_id":1,"message":{"message_id":1,"chat":{"id":42},"successful_payment":{"currency":"USD","total_amount":400,"invoice_payload":"{\"plan\":\"premium\"}","telegram_payment_charge_id":"charge1"}}}`), update)
+	a.handleUpdate(update)
+
+	telebot.AssertExpectations(t)
+}
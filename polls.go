@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+)
+
+// pollSettings converts poll settings object to request parameters
+func pollSettings(settings map[string]interface{}) func(url.Values) {
+	return func(r url.Values) {
+		if anonymous, ok := settings["anonymous"].(bool); ok {
+			r.Set("is_anonymous", strconv.FormatBool(anonymous))
+		}
+		if boolField(settings, "multiple") {
+			r.Set("allows_multiple_answers", "true")
+		}
+		if explanation, ok := stringField(settings, "explanation"); ok {
+			r.Set("explanation", explanation)
+			r.Set("explanation_parse_mode", "HTML")
+		}
+		if openPeriod, ok := stringField(settings, "open_period"); ok {
+			r.Set("open_period", openPeriod)
+		}
+		if closeDate, ok := stringField(settings, "close_date"); ok {
+			r.Set("close_date", closeDate)
+		}
+		if correctOption, ok := stringField(settings, "correct_option_id"); ok {
+			r.Set("type", "quiz")
+			r.Set("correct_option_id", correctOption)
+		}
+	}
+}
+
+// sendPoll sends a poll and returns message id and poll id, poll id identifies answers passed to bot.onPollAnswer
+func (a *application) sendPoll(chatID string, question string, options []string, settings map[string]interface{}) (int, string) {
+	id, pollID, err := a.tgClient.CreatePoll(chatID, question, options, pollSettings(settings))
+	if err != nil {
+		log.Error("Error sending poll ", err)
+	}
+	return id, pollID
+}
+
+func (a *application) handlePoll(p *Poll) {
+	if !a.hasHandler("onPoll") {
+		return
+	}
+
+	if _, err := a.callHandler("", "onPoll", p); err != nil {
+		log.Error("Error in handlePoll ", err)
+	}
+}
+
+// handlePollAnswer calls bot.onPollAnswer bound to the private chat of the voter
+func (a *application) handlePollAnswer(pa *PollAnswer) {
+	if !a.hasHandler("onPollAnswer") {
+		return
+	}
+
+	chatID := ""
+	if pa.User != nil {
+		chatID = strconv.Itoa(pa.User.ID)
+	}
+
+	if _, err := a.callHandler(chatID, "onPollAnswer", pa); err != nil {
+		log.Error("Error in handlePollAnswer ", err)
+	}
+}
+
+// pollArguments reads question and options arguments of sendPoll and sendQuiz, and settings argument at the index
+func pollArguments(call otto.FunctionCall, settingsArg int) (question string, options []string, settings map[string]interface{}) {
+	question, _ = call.Argument(0).ToString()
+
+	optionsInterface, _ := exportJSON(call.Otto, call.Argument(1))
+	list, _ := toSlice(optionsInterface)
+	for _, option := range list {
+		options = append(options, fmt.Sprintf("%v", option))
+	}
+
+	settingsInterface, _ := exportJSON(call.Otto, call.Argument(settingsArg))
+	if settings, _ = settingsInterface.(map[string]interface{}); settings == nil {
+		settings = map[string]interface{}{}
+	}
+
+	return
+}
+
+func (a *application) getSendPollFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		question, options, settings := pollArguments(call, 2)
+
+		id, pollID := a.sendPoll(chatIDArgument(call.Argument(3), userID), question, options, settings)
+
+		return toJsValue(call.Otto, map[string]interface{}{"id": id, "pollId": pollID})
+	}
+}
+
+func (a *application) getSendQuizFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		question, options, settings := pollArguments(call, 3)
+		settings["correct_option_id"], _ = call.Argument(2).ToInteger()
+
+		id, pollID := a.sendPoll(chatIDArgument(call.Argument(4), userID), question, options, settings)
+
+		return toJsValue(call.Otto, map[string]interface{}{"id": id, "pollId": pollID})
+	}
+}
+
+// stopPoll stops a poll and returns its final results, the same Poll as passed to bot.onPoll
+func (a *application) stopPoll(chatID string, msgID int) *Poll {
+	data, err := a.tgClient.ClosePoll(chatID, msgID)
+	if err != nil {
+		log.Error("Error stopping poll ", err)
+		return nil
+	}
+
+	poll := &Poll{}
+	if err := json.Unmarshal([]byte(data), poll); err != nil {
+		log.Error("Error stopping poll ", err)
+		return nil
+	}
+
+	return poll
+}
+
+func (a *application) getStopPollFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		result := otto.Value{}
+
+		if chatID, err := call.Argument(0).ToString(); err == nil {
+			if msgID, err := call.Argument(1).ToInteger(); err == nil {
+				if poll := a.stopPoll(chatID, int(msgID)); poll != nil {
+					result, _ = call.Otto.ToValue(poll)
+				}
+			}
+		}
+
+		return result
+	}
+}
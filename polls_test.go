@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSendPoll(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot}
+
+	vm := otto.New()
+	vm.Set("sendPoll", a.getSendPollFunc(userID))
+	vm.Set("sendQuiz", a.getSendQuizFunc(userID))
+	vm.Set("stopPoll", a.getStopPollFunc())
+
+	var requests []url.Values
+	capture := func(args mock.Arguments) {
+		r := url.Values{}
+		args.Get(3).(func(url.Values))(r)
+		requests = append(requests, r)
+	}
+	telebot.On("CreatePoll", userID, "Lunch?", []string{"Pizza", "Sushi"}, mock.AnythingOfType("func(url.Values)")).Return(1, "poll1", nil).Run(capture)
+	telebot.On("CreatePoll", "456", "2 + 2", []string{"3", "4"}, mock.AnythingOfType("func(url.Values)")).Return(2, "poll2", nil).Run(capture)
+	telebot.On("ClosePoll", userID, 1).Return(`{"id":"poll1","options":[{"text":"Pizza","voter_count":3}],"is_closed":true,"type":"regular"}`, nil)
+
+	val, err := vm.Run(`
+		var poll = sendPoll("Lunch?", ["Pizza", "Sushi"], {anonymous: false, multiple: true})
+		var quiz = sendQuiz("2 + 2", [3, 4], 1, {explanation: "Basic math"}, "456")
+		var results = stopPoll("` + userID + `", poll.id);
+		[poll.id, poll.pollId, quiz.id, quiz.pollId, results.Options[0].VoterCount, results.Type, results.IsClosed].join(",")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1,poll1,2,poll2,3,regular,true", val.String())
+	telebot.AssertExpectations(t)
+
+	assert.Equal(t, url.Values{"is_anonymous": {"false"}, "allows_multiple_answers": {"true"}}, requests[0])
+	assert.Equal(t, url.Values{"type": {"quiz"}, "correct_option_id": {"1"}, "explanation": {"Basic math"}, "explanation_parse_mode": {"HTML"}}, requests[1])
+}
+
+func TestTbotWrapperClosePoll(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bottoken/stopPoll", r.URL.Path)
+		assert.Equal(t, userID, r.FormValue("chat_id"))
+		assert.Equal(t, "7", r.FormValue("message_id"))
+
+		rw.Write([]byte(`{"ok":true,"result":{"id":"poll1","type":"quiz","is_anonymous":false,"correct_option_id":1}}`))
+	}))
+	defer server.Close()
+
+	defaultURL := apiBaseURL
+	apiBaseURL = server.URL
+	defer func() { apiBaseURL = defaultURL }()
+
+	telebot := &TbotWrapper{token: "token"}
+	a := &application{tgClient: telebot}
+
+	correct := 1
+	assert.Equal(t, &Poll{ID: "poll1", Type: "quiz", CorrectOptionID: &correct}, a.stopPoll(userID, 7))
+}
+
+func TestSendPollToCurrentChat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "polls_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "bot.js")
+	ioutil.WriteFile(script, []byte(`bot = {}`), 0644)
+
+	os.Setenv("SCRIPTS", script)
+	defer os.Unsetenv("SCRIPTS")
+
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, vmFactory: VmFactoryImpl{}}
+	assert.Nil(t, a.reloadScripts())
+
+	telebot.On("CreatePoll", userID, "Lunch?", []string{"Pizza", "Sushi"}, mock.AnythingOfType("func(url.Values)")).Return(1, "poll1", nil)
+	telebot.On("CreatePoll", userID, "2 + 2", []string{"3", "4"}, mock.AnythingOfType("func(url.Values)")).Return(2, "poll2", nil)
+
+	//handler vm sends polls to its chat when chat id is omitted
+	val, err := a.getVm(userID).Run(`[sendPoll("Lunch?", ["Pizza", "Sushi"]).pollId, sendQuiz("2 + 2", [3, 4], 1).pollId].join(",")`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "poll1,poll2", val.String())
+	telebot.AssertExpectations(t)
+}
+
+func TestHandlePollUpdates(t *testing.T) {
+	telebot := &mocks.Telebot{}
+	vm := VmFactoryImpl{}.GetVm()
+	a := &application{tgClient: telebot, vmTemplate: vm}
+	vm.Set("send", a.getSendFunc(""))
+	vm.Run(`bot = {
+		onPollAnswer: function (answer) { send(answer.PollID + ":" + answer.OptionIDs.join(",")) },
+		onPoll: function (poll) { send(poll.Type + ":" + poll.TotalVoterCount, null, null, "456") }
+	}`)
+
+	telebot.On("SendText", "42", "poll1:0,2", mock.AnythingOfType("func(url.Values)")).Return(1, nil)
+	telebot.On("SendText", "456", "quiz:5", mock.AnythingOfType("func(url.Values)")).Return(2, nil)
+
+	for _, data := range []string{
+		`{"update_id":1,"poll_answer":{"poll_id":"poll1","user":{"id":42,"first_name":"John"},"option_ids":[0,2]}}`,
+		`{"update_id":2,"poll":{"id":"poll1","question":"2 + 2","options":[{"text":"4","voter_count":5}],"total_voter_count":5,"type":"quiz","correct_option_id":0}}`,
+	} {
+		update := &Update{}
+		assert.Nil(t, json.Unmarshal([]byte(data), update))
+		a.handleUpdate(update)
+	}
+
+	telebot.AssertExpectations(t)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+// reloadScripts rebuilds template vm and swaps it only if the new build succeeds
+func (a *application) reloadScripts() error {
+	build, err := a.loadScripts()
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.vmTemplate, a.scriptFiles = build.vm, build.files
+	a.mu.Unlock()
+
+	if a.scheduler != nil {
+		a.scheduler.replace(sourceScripts, build.schedules)
+	}
+
+	log.Info("Scripts reloaded")
+
+	return nil
+}
+
+// watchScripts reloads scripts on SIGHUP and, if interval is positive, when any of loaded files changes
+func (a *application) watchScripts(interval time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	modTimes := a.scriptModTimes()
+
+	for {
+		select {
+		case <-signals:
+			log.Info("Received SIGHUP, reloading scripts")
+		case <-ticks:
+			current := a.scriptModTimes()
+			if !modTimesChanged(modTimes, current) {
+				continue
+			}
+			modTimes = current
+			log.Info("Scripts changed, reloading")
+		}
+
+		if err := a.reloadScripts(); err != nil {
+			log.Error("Error reloading scripts, keeping previous version ", err)
+			continue
+		}
+		modTimes = a.scriptModTimes()
+	}
+}
+
+func (a *application) scriptModTimes() map[string]time.Time {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	modTimes := map[string]time.Time{}
+	for _, file := range a.scriptFiles {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+
+	return modTimes
+}
+
+func modTimesChanged(prev map[string]time.Time, current map[string]time.Time) bool {
+	if len(prev) != len(current) {
+		return true
+	}
+	for file, modTime := range current {
+		if !prev[file].Equal(modTime) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadScripts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "bot.js")
+	ioutil.WriteFile(script, []byte(`bot = { version: 1 }`), 0644)
+
+	os.Setenv("SCRIPTS", script)
+	defer os.Unsetenv("SCRIPTS")
+
+	a := &application{vmFactory: VmFactoryImpl{}}
+	assert.Nil(t, a.reloadScripts())
+
+	version := func() int64 {
+		val, _ := a.template().Run("bot.version")
+		v, _ := val.ToInteger()
+		return v
+	}
+	assert.Equal(t, int64(1), version())
+
+	//successful reload swaps template
+	ioutil.WriteFile(script, []byte(`bot = { version: 2 }`), 0644)
+
+	assert.Nil(t, a.reloadScripts())
+	assert.Equal(t, int64(2), version())
+
+	//broken script keeps previous template
+	ioutil.WriteFile(script, []byte(`bot = { version: `), 0644)
+
+	assert.NotNil(t, a.reloadScripts())
+	assert.Equal(t, int64(2), version())
+
+	//missing bot object keeps previous template
+	ioutil.WriteFile(script, []byte(`var x = 1`), 0644)
+
+	assert.NotNil(t, a.reloadScripts())
+	assert.Equal(t, int64(2), version())
+}
+
+func TestModTimesChanged(t *testing.T) {
+	now := time.Now()
+
+	assert.False(t, modTimesChanged(map[string]time.Time{"a": now}, map[string]time.Time{"a": now}))
+	assert.True(t, modTimesChanged(map[string]time.Time{"a": now}, map[string]time.Time{"a": now.Add(time.Second)}))
+	assert.True(t, modTimesChanged(map[string]time.Time{"a": now}, map[string]time.Time{"a": now, "b": now}))
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+// job sources, jobs of a source are replaced together, e.g. on scripts reload
+const (
+	sourceConfig  = "config"
+	sourceScripts = "scripts"
+	sourceRuntime = "runtime"
+)
+
+type scheduleSpec struct {
+	spec     string
+	function string
+	timezone string
+}
+
+type scheduledJob struct {
+	scheduleSpec
+	schedule *cronSchedule
+	running  int32
+	stop     chan struct{}
+}
+
+// scheduler invokes js functions according to cron schedules, a run is skipped while previous one is in progress
+type scheduler struct {
+	mu      sync.Mutex
+	run     func(function string)
+	jobs    map[string]map[string]*scheduledJob
+	started bool
+}
+
+func newScheduler(run func(function string)) *scheduler {
+	return &scheduler{run: run, jobs: map[string]map[string]*scheduledJob{}}
+}
+
+func newScheduledJob(spec scheduleSpec) (*scheduledJob, error) {
+	location := time.Local
+	timezone := spec.timezone
+	if timezone == "" {
+		timezone = GetEnv("SCHEDULE_TZ", "")
+	}
+	if timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	schedule, err := parseCron(spec.spec, location)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scheduledJob{scheduleSpec: spec, schedule: schedule, stop: make(chan struct{})}, nil
+}
+
+// parseSchedules parses list of "<cron expression> <function>" entries separated by semicolon
+func parseSchedules(list string) ([]scheduleSpec, error) {
+	var specs []scheduleSpec
+
+	for _, entry := range strings.Split(list, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, " ")
+		if i < 0 {
+			return nil, fmt.Errorf("Schedule %q must be followed by function name", entry)
+		}
+		specs = append(specs, scheduleSpec{spec: strings.TrimSpace(entry[:i]), function: entry[i+1:]})
+	}
+
+	return specs, nil
+}
+
+// add registers a job, a job with the same function and expression in the source is replaced
+func (s *scheduler) add(source string, spec scheduleSpec) error {
+	job, err := newScheduledJob(spec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.jobs[source] == nil {
+		s.jobs[source] = map[string]*scheduledJob{}
+	}
+	key := job.key()
+	if prev, ok := s.jobs[source][key]; ok && s.started {
+		close(prev.stop)
+	}
+	s.jobs[source][key] = job
+	if s.started {
+		go s.loop(job)
+	}
+
+	return nil
+}
+
+// replace stops all jobs of the source and registers new ones, invalid specs are logged and skipped
+func (s *scheduler) replace(source string, specs []scheduleSpec) {
+	jobs := map[string]*scheduledJob{}
+	for _, spec := range specs {
+		job, err := newScheduledJob(spec)
+		if err != nil {
+			log.Errorf("Error scheduling %s with %q: %v", spec.function, spec.spec, err)
+			continue
+		}
+		jobs[job.key()] = job
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		for _, job := range s.jobs[source] {
+			close(job.stop)
+		}
+		for _, job := range jobs {
+			go s.loop(job)
+		}
+	}
+	s.jobs[source] = jobs
+}
+
+// start launches registered jobs, jobs added afterwards are launched immediately
+func (s *scheduler) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+	for _, jobs := range s.jobs {
+		for _, job := range jobs {
+			go s.loop(job)
+		}
+	}
+}
+
+func (s *scheduler) loop(job *scheduledJob) {
+	for {
+		now := time.Now()
+		next := job.schedule.Next(now)
+		if next.IsZero() {
+			log.Warnf("Schedule %q of %s never fires", job.spec, job.function)
+			return
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-job.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			go s.fire(job)
+		}
+	}
+}
+
+func (s *scheduler) fire(job *scheduledJob) {
+	if !atomic.CompareAndSwapInt32(&job.running, 0, 1) {
+		log.Warnf("Skipped %s scheduled with %q, previous run is still in progress", job.function, job.spec)
+		return
+	}
+	defer atomic.StoreInt32(&job.running, 0)
+
+	s.run(job.function)
+}
+
+func (j *scheduledJob) key() string {
+	return j.function + "|" + j.spec + "|" + j.timezone
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchedules(t *testing.T) {
+	specs, err := parseSchedules("0 9 * * MON weeklyDigest; @every 5m bot.onTimer;")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []scheduleSpec{
+		{spec: "0 9 * * MON", function: "weeklyDigest"},
+		{spec: "@every 5m", function: "bot.onTimer"},
+	}, specs)
+
+	_, err = parseSchedules("weeklyDigest")
+	assert.NotNil(t, err)
+}
+
+func TestSchedulerOverlap(t *testing.T) {
+	var runs, active, overlaps int32
+	s := newScheduler(func(function string) {
+		if atomic.AddInt32(&active, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(time.Millisecond * 50)
+		atomic.AddInt32(&active, -1)
+	})
+
+	s.replace(sourceScripts, []scheduleSpec{{spec: "@every 10ms", function: "slow"}, {spec: "invalid", function: "skipped"}})
+	assert.Equal(t, 1, len(s.jobs[sourceScripts]))
+
+	s.start()
+	time.Sleep(time.Millisecond * 120)
+
+	//stop jobs
+	s.replace(sourceScripts, nil)
+
+	assert.True(t, atomic.LoadInt32(&runs) >= 1)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&overlaps))
+}
+
+func TestSchedulerAdd(t *testing.T) {
+	calls := make(chan string, 10)
+	s := newScheduler(func(function string) {
+		calls <- function
+	})
+	s.start()
+
+	assert.NotNil(t, s.add(sourceRuntime, scheduleSpec{spec: "* * *", function: "broken"}))
+	assert.Nil(t, s.add(sourceRuntime, scheduleSpec{spec: "@every 10ms", function: "tick"}))
+
+	select {
+	case function := <-calls:
+		assert.Equal(t, "tick", function)
+	case <-time.After(time.Second):
+		t.Errorf("Expected scheduled function call")
+	}
+
+	s.replace(sourceRuntime, nil)
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ReneKroon/ttlcache"
+	"github.com/labstack/gommon/log"
+)
+
+// SessionStore keeps values put by scripts, values must be json serializable
+type SessionStore interface {
+	Set(key string, value interface{}) error
+	Get(key string) (interface{}, error)
+	Remove(key string) error
+}
+
+// KeyLister is implemented by persistent stores which can list keys of entries kept before restart
+type KeyLister interface {
+	Keys(prefix string) ([]string, error)
+}
+
+func newSessionStore(kind string, ttl time.Duration, db *sql.DB) (SessionStore, error) {
+	switch kind {
+	case "", "memory":
+		return newMemoryStore(ttl), nil
+	case "file":
+		return newFileStore(GetEnv("SESSION_FILE", "sessions.json"), ttl)
+	case "sql":
+		if db == nil {
+			return nil, fmt.Errorf("Session store %s requires db connection", kind)
+		}
+		return newSQLStore(db, GetEnv("DB_DRIVER", ""), GetEnv("SESSION_TABLE", "bot_sessions"), ttl)
+	default:
+		return nil, fmt.Errorf("Unknown session store %s", kind)
+	}
+}
+
+type memoryStore struct {
+	cache *ttlcache.Cache
+}
+
+func newMemoryStore(ttl time.Duration) *memoryStore {
+	cache := ttlcache.NewCache()
+	cache.SetTTL(ttl)
+	return &memoryStore{cache: cache}
+}
+
+func (m *memoryStore) Set(key string, value interface{}) error {
+	m.cache.Set(key, value)
+	return nil
+}
+
+func (m *memoryStore) Get(key string) (interface{}, error) {
+	val, _ := m.cache.Get(key)
+	return val, nil
+}
+
+func (m *memoryStore) Remove(key string) error {
+	m.cache.Remove(key)
+	return nil
+}
+
+type storeEntry struct {
+	Value   json.RawMessage `json:"value"`
+	Expires int64           `json:"expires"`
+}
+
+func (e storeEntry) expired(now time.Time) bool {
+	return e.Expires > 0 && e.Expires <= now.Unix()
+}
+
+// fileStoreFlushDelay is how long ttl extended by reads may stay unwritten if nothing is written
+const fileStoreFlushDelay = time.Minute
+
+// fileStore keeps all entries in memory and flushes them to json file on every change
+type fileStore struct {
+	mu      sync.Mutex
+	path    string
+	ttl     time.Duration
+	entries map[string]storeEntry
+	dirty   bool
+}
+
+func newFileStore(path string, ttl time.Duration) (*fileStore, error) {
+	f := &fileStore{path: path, ttl: ttl, entries: map[string]storeEntry{}}
+
+	if FileExists(path) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &f.entries); err != nil {
+				return nil, fmt.Errorf("Error parsing session file %s: %v", path, err)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+func (f *fileStore) Set(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.entries[key] = storeEntry{Value: data, Expires: expiresAt(f.ttl)}
+
+	return f.flush()
+}
+
+func (f *fileStore) Get(key string) (interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, ok := f.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	if entry.expired(time.Now()) {
+		delete(f.entries, key)
+		f.markDirty()
+		return nil, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(entry.Value, &value); err != nil {
+		return nil, err
+	}
+
+	//reading extends ttl like in memory store, extension is written to file later to keep reads cheap
+	if f.ttl > 0 {
+		entry.Expires = expiresAt(f.ttl)
+		f.entries[key] = entry
+		f.markDirty()
+	}
+
+	return value, nil
+}
+
+func (f *fileStore) Remove(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.entries[key]; !ok {
+		return nil
+	}
+	delete(f.entries, key)
+
+	return f.flush()
+}
+
+// Keys returns keys of not expired entries starting with prefix
+func (f *fileStore) Keys(prefix string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	var keys []string
+	for key, entry := range f.entries {
+		if strings.HasPrefix(key, prefix) && !entry.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// markDirty schedules flush of changes made by reads, they are flushed earlier by any write. Must be called under lock
+func (f *fileStore) markDirty() {
+	if f.dirty {
+		return
+	}
+	f.dirty = true
+	time.AfterFunc(fileStoreFlushDelay, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if !f.dirty {
+			return
+		}
+		if err := f.flush(); err != nil {
+			log.Error("Error flushing session file ", err)
+		}
+	})
+}
+
+// flush purges expired entries and atomically replaces the file, must be called under lock
+func (f *fileStore) flush() error {
+	f.dirty = false
+
+	now := time.Now()
+	for key, entry := range f.entries {
+		if entry.expired(now) {
+			delete(f.entries, key)
+		}
+	}
+
+	data, err := json.Marshal(f.entries)
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(f.path, data)
+}
+
+// sqlStore keeps entries in a table of the configured database
+type sqlStore struct {
+	db     *sql.DB
+	driver string
+	table  string
+	ttl    time.Duration
+}
+
+func newSQLStore(db *sql.DB, driver string, table string, ttl time.Duration) (*sqlStore, error) {
+	s := &sqlStore{db: db, driver: driver, table: table, ttl: ttl}
+
+	_, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (session_key VARCHAR(255) PRIMARY KEY, session_value TEXT, expires_at BIGINT)", table))
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *sqlStore) Set(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(s.upsertQuery(), key, string(data), expiresAt(s.ttl))
+	return err
+}
+
+// upsertQuery inserts entry or replaces existing one in a single statement, so that concurrent sets do not conflict
+func (s *sqlStore) upsertQuery() string {
+	insert := fmt.Sprintf("INSERT INTO %s (session_key, session_value, expires_at) VALUES (%s, %s, %s)",
+		s.table, bindVar(s.driver, 1), bindVar(s.driver, 2), bindVar(s.driver, 3))
+
+	if s.driver == "postgres" {
+		return insert + " ON CONFLICT (session_key) DO UPDATE SET session_value = EXCLUDED.session_value, expires_at = EXCLUDED.expires_at"
+	}
+	return insert + " ON DUPLICATE KEY UPDATE session_value = VALUES(session_value), expires_at = VALUES(expires_at)"
+}
+
+func (s *sqlStore) Get(key string) (interface{}, error) {
+	var entry storeEntry
+	var data string
+
+	err := s.db.QueryRow(fmt.Sprintf("SELECT session_value, expires_at FROM %s WHERE session_key = %s", s.table, bindVar(s.driver, 1)), key).
+		Scan(&data, &entry.Expires)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.expired(time.Now()) {
+		return nil, s.Remove(key)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return nil, err
+	}
+
+	//reading extends ttl like in memory store
+	if s.ttl > 0 {
+		if _, err := s.db.Exec(fmt.Sprintf("UPDATE %s SET expires_at = %s WHERE session_key = %s", s.table, bindVar(s.driver, 1), bindVar(s.driver, 2)),
+			expiresAt(s.ttl), key); err != nil {
+			log.Error("Error extending session ttl ", err)
+		}
+	}
+
+	return value, nil
+}
+
+func (s *sqlStore) Remove(key string) error {
+	_, err := s.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE session_key = %s", s.table, bindVar(s.driver, 1)), key)
+	return err
+}
+
+// Keys returns keys of entries starting with prefix, expired entries are removed when they are read
+func (s *sqlStore) Keys(prefix string) ([]string, error) {
+	rows, err := s.db.Query(fmt.Sprintf("SELECT session_key FROM %s WHERE session_key LIKE %s ORDER BY session_key", s.table, bindVar(s.driver, 1)),
+		prefix+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// expiresAt returns unix time of entry expiration, zero means entry never expires.
+// Entries of all stores expire after ttl since they were last written or read
+func expiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).Unix()
+}
+
+// bindVar returns n-th query placeholder for the driver
+func bindVar(driver string, n int) string {
+	if driver == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
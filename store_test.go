@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sessions.json")
+
+	store, err := newFileStore(path, time.Hour)
+	assert.Nil(t, err)
+
+	assert.Nil(t, store.Set("key", map[string]interface{}{"step": 1}))
+
+	//survives restart
+	store, err = newFileStore(path, time.Hour)
+	assert.Nil(t, err)
+
+	val, err := store.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"step": float64(1)}, val)
+
+	assert.Nil(t, store.Remove("key"))
+
+	val, err = store.Get("key")
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+
+	//reading extends ttl in memory, it is written with the next change
+	store.entries["key"] = storeEntry{Value: []byte(`1`), Expires: time.Now().Add(time.Minute).Unix()}
+	before, _ := ioutil.ReadFile(path)
+	val, err = store.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), val)
+	assert.True(t, store.entries["key"].Expires >= time.Now().Add(time.Hour-time.Minute).Unix())
+
+	after, _ := ioutil.ReadFile(path)
+	assert.Equal(t, before, after)
+	assert.True(t, store.dirty)
+
+	assert.Nil(t, store.Set("other", 2))
+	assert.False(t, store.dirty)
+
+	store, err = newFileStore(path, time.Hour)
+	assert.Nil(t, err)
+	assert.True(t, store.entries["key"].Expires >= time.Now().Add(time.Hour-time.Minute).Unix())
+
+	keys, err := store.Keys("ke")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"key"}, keys)
+
+	//expired entry
+	store.entries["old"] = storeEntry{Value: []byte(`1`), Expires: time.Now().Add(-time.Minute).Unix()}
+
+	val, err = store.Get("old")
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+}
+
+func TestSQLStore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS sessions").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	store, err := newSQLStore(db, "postgres", "sessions", time.Hour)
+	assert.Nil(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO sessions (session_key, session_value, expires_at) VALUES ($1, $2, $3) ON CONFLICT (session_key) DO UPDATE")).
+		WithArgs("key", `{"step":1}`, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	assert.Nil(t, store.Set("key", map[string]interface{}{"step": 1}))
+
+	mock.ExpectQuery("SELECT session_value, expires_at FROM sessions").WithArgs("key").
+		WillReturnRows(sqlmock.NewRows([]string{"session_value", "expires_at"}).AddRow(`{"step":1}`, time.Now().Add(time.Hour).Unix()))
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE sessions SET expires_at = $1 WHERE session_key = $2")).WithArgs(sqlmock.AnyArg(), "key").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	val, err := store.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"step": float64(1)}, val)
+
+	//failed ttl extension does not fail read
+	mock.ExpectQuery("SELECT session_value, expires_at FROM sessions").WithArgs("key").
+		WillReturnRows(sqlmock.NewRows([]string{"session_value", "expires_at"}).AddRow(`{"step":1}`, time.Now().Add(time.Hour).Unix()))
+	mock.ExpectExec("UPDATE sessions").WillReturnError(errors.New("connection reset"))
+
+	val, err = store.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"step": float64(1)}, val)
+
+	//expired entry is removed
+	mock.ExpectQuery("SELECT session_value, expires_at FROM sessions").WithArgs("key").
+		WillReturnRows(sqlmock.NewRows([]string{"session_value", "expires_at"}).AddRow(`{"step":1}`, time.Now().Add(-time.Hour).Unix()))
+	mock.ExpectExec("DELETE FROM sessions").WithArgs("key").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	val, err = store.Get("key")
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT session_key FROM sessions WHERE session_key LIKE $1")).WithArgs("captcha|%").
+		WillReturnRows(sqlmock.NewRows([]string{"session_key"}).AddRow("captcha|-100123|42"))
+
+	keys, err := store.Keys("captcha|")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"captcha|-100123|42"}, keys)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSetGetFunc(t *testing.T) {
+	a := &application{cache: newMemoryStore(time.Minute)}
+	vm := otto.New()
+	vm.Set("set", a.getSetFunc(userID))
+	vm.Set("get", a.getGetFunc(userID))
+
+	val, err := vm.Run(`set("step", {id: 1}); var s = get("step"); s.id++; s.id + get("step").id`)
+
+	assert.Nil(t, err)
+	res, _ := val.ToInteger()
+	assert.Equal(t, int64(3), res)
+}
+
+func TestSQLStoreUpsert(t *testing.T) {
+	store := &sqlStore{driver: "mysql", table: "sessions"}
+
+	assert.Equal(t, "INSERT INTO sessions (session_key, session_value, expires_at) VALUES (?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE session_value = VALUES(session_value), expires_at = VALUES(expires_at)", store.upsertQuery())
+}
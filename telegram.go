@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// base url of telegram bot api, variable to allow substitution in tests
+var apiBaseURL = "https://api.telegram.org"
+
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	Description string          `json:"description"`
+	ErrorCode   int             `json:"error_code"`
+}
+
+// callAPI invokes bot api method which is not covered by tbot client
+func callAPI(token string, method string, request url.Values, result interface{}) error {
+	client := &http.Client{Timeout: time.Second * 30}
+
+	resp, err := client.PostForm(apiURL(token, method), request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return decodeAPIResponse(method, resp, result)
+}
+
+// callAPIWithFiles invokes bot api method uploading files, files map multipart field names to local paths
+func callAPIWithFiles(token string, method string, request url.Values, files map[string]string, result interface{}) error {
+	return callAPIMultipart(token, method, request, func(mw *multipart.Writer) error {
+		for field, path := range files {
+			if err := writeFilePart(mw, field, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, result)
+}
+
+// callAPIWithContent invokes bot api method uploading in-memory content as file with the name
+func callAPIWithContent(token string, method string, request url.Values, field string, name string, content []byte, result interface{}) error {
+	return callAPIMultipart(token, method, request, func(mw *multipart.Writer) error {
+		part, err := mw.CreateFormFile(field, name)
+		if err != nil {
+			return err
+		}
+		_, err = part.Write(content)
+		return err
+	}, result)
+}
+
+// callAPIMultipart invokes bot api method with request fields and file parts written by writeParts
+func callAPIMultipart(token string, method string, request url.Values, writeParts func(mw *multipart.Writer) error, result interface{}) error {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+
+	for key := range request {
+		if err := mw.WriteField(key, request.Get(key)); err != nil {
+			return err
+		}
+	}
+	if err := writeParts(mw); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: time.Minute * 5}
+
+	resp, err := client.Post(apiURL(token, method), mw.FormDataContentType(), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return decodeAPIResponse(method, resp, result)
+}
+
+func writeFilePart(mw *multipart.Writer, field string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	part, err := mw.CreateFormFile(field, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, file)
+
+	return err
+}
+
+func apiURL(token string, method string) string {
+	return fmt.Sprintf("%s/bot%s/%s", apiBaseURL, token, method)
+}
+
+// fileURL returns download link of file by its path returned by getFile, the link contains bot token
+func fileURL(token string, path string) string {
+	return fmt.Sprintf("%s/file/bot%s/%s", apiBaseURL, token, path)
+}
+
+func decodeAPIResponse(method string, resp *http.Response, result interface{}) error {
+	apiResp := &apiResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(apiResp); err != nil {
+		return fmt.Errorf("Unable to decode %s response: %v", method, err)
+	}
+	if !apiResp.OK {
+		return fmt.Errorf("%s failed: %s", method, apiResp.Description)
+	}
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(apiResp.Result, result)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+)
+
+// timeoutKey identifies a timeout by chat and function, so a function has at most one pending timeout per chat
+func timeoutKey(chatID string, function string) string {
+	return chatID + "|" + function
+}
+
+// setUserTimeout calls js function in a vm bound to the chat after delay, pending timeout of the same function is replaced
+func (a *application) setUserTimeout(chatID string, delay time.Duration, function string, payload interface{}) {
+	key := timeoutKey(chatID, function)
+
+	a.timeoutsMu.Lock()
+	defer a.timeoutsMu.Unlock()
+
+	if a.timeouts == nil {
+		a.timeouts = map[string]*time.Timer{}
+	}
+	if prev, ok := a.timeouts[key]; ok {
+		prev.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		a.timeoutsMu.Lock()
+		if a.timeouts[key] != timer {
+			a.timeoutsMu.Unlock()
+			return
+		}
+		delete(a.timeouts, key)
+		a.timeoutsMu.Unlock()
+
+		if _, err := a.callFunction(chatID, function, payload); err != nil {
+			log.Error("Error in timeout "+function+" ", err)
+		}
+	})
+	a.timeouts[key] = timer
+}
+
+// clearUserTimeout cancels pending timeout of the function, or all pending timeouts of the chat if function is empty
+func (a *application) clearUserTimeout(chatID string, function string) bool {
+	a.timeoutsMu.Lock()
+	defer a.timeoutsMu.Unlock()
+
+	cleared := false
+	for key, timer := range a.timeouts {
+		if key == timeoutKey(chatID, function) || (function == "" && strings.HasPrefix(key, chatID+"|")) {
+			timer.Stop()
+			delete(a.timeouts, key)
+			cleared = true
+		}
+	}
+
+	return cleared
+}
+
+// chatIDArgument returns chat id passed to js function or userID if it is omitted
+func chatIDArgument(val otto.Value, userID string) string {
+	if val.IsDefined() && !val.IsNull() {
+		if chatID, err := val.ToString(); err == nil {
+			return chatID
+		}
+	}
+
+	return userID
+}
+
+func (a *application) getSetUserTimeoutFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		result, _ := otto.ToValue(false)
+
+		chatID := chatIDArgument(call.Argument(0), userID)
+		if chatID == "" {
+			log.Error("Error setting timeout, chat id is missing")
+			return result
+		}
+
+		delay, err := parseDelay(call.Argument(1))
+		if err != nil {
+			log.Error("Error setting timeout ", err)
+			return result
+		}
+
+		function, _ := call.Argument(2).ToString()
+		if !functionNameRegexp.MatchString(function) {
+			log.Error("Error setting timeout, invalid function name ", function)
+			return result
+		}
+
+		payload, _ := call.Argument(3).Export()
+
+		a.setUserTimeout(chatID, delay, function, payload)
+
+		result, _ = otto.ToValue(true)
+		return result
+	}
+}
+
+func (a *application) getClearUserTimeoutFunc(userID string) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		function := ""
+		if call.Argument(1).IsString() {
+			function = call.Argument(1).String()
+		}
+
+		result, _ := otto.ToValue(a.clearUserTimeout(chatIDArgument(call.Argument(0), userID), function))
+
+		return result
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dilshat/telegram-bot/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSetUserTimeout(t *testing.T) {
+	vm := VmFactoryImpl{}.GetVm()
+	vm.Run(`function expired(payload) { send("Timed out at step " + payload.step) }`)
+
+	telebot := &mocks.Telebot{}
+	a := &application{tgClient: telebot, vmTemplate: vm}
+	vm.Set("setUserTimeout", a.getSetUserTimeoutFunc(""))
+	vm.Set("clearUserTimeout", a.getClearUserTimeoutFunc(""))
+
+	sent := make(chan string, 1)
+	telebot.On("SendText", userID, "Timed out at step 2", mock.AnythingOfType("func(url.Values)")).Return(1, nil).
+		Run(func(args mock.Arguments) {
+			sent <- args.String(0)
+		})
+
+	//pending timeout of the same function is replaced
+	res, err := vm.Run(`setUserTimeout("` + userID + `", 50, "expired", {step: 1}) && setUserTimeout("` + userID + `", "50ms", "expired", {step: 2})`)
+	assert.Nil(t, err)
+	assert.Equal(t, "true", res.String())
+
+	select {
+	case chatID := <-sent:
+		assert.Equal(t, userID, chatID)
+	case <-time.After(time.Second):
+		t.Fatal("Timeout function was not called")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	telebot.AssertNumberOfCalls(t, "SendText", 1)
+
+	//cleared timeout never fires
+	res, _ = vm.Run(`setUserTimeout("` + userID + `", 50, "expired", {step: 3}); clearUserTimeout("` + userID + `", "expired")`)
+	assert.Equal(t, "true", res.String())
+
+	res, _ = vm.Run(`clearUserTimeout("` + userID + `")`)
+	assert.Equal(t, "false", res.String())
+
+	res, _ = vm.Run(`setUserTimeout(null, 50, "expired")`)
+	assert.Equal(t, "false", res.String())
+
+	time.Sleep(100 * time.Millisecond)
+	telebot.AssertNumberOfCalls(t, "SendText", 1)
+}
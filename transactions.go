@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/robertkrimen/otto"
+)
+
+// isolation levels accepted by dbTransaction, names are compared ignoring case, spaces and underscores
+var isolationLevels = map[string]sql.IsolationLevel{
+	"default":         sql.LevelDefault,
+	"readuncommitted": sql.LevelReadUncommitted,
+	"readcommitted":   sql.LevelReadCommitted,
+	"writecommitted":  sql.LevelWriteCommitted,
+	"repeatableread":  sql.LevelRepeatableRead,
+	"snapshot":        sql.LevelSnapshot,
+	"serializable":    sql.LevelSerializable,
+	"linearizable":    sql.LevelLinearizable,
+}
+
+// callInTransaction calls callback with transaction object, exception thrown by callback is returned
+// instead of being thrown so the transaction is rolled back before it is rethrown unchanged
+const callInTransaction = `(function (callback, tx) {
+	try {
+		return {result: callback(tx)};
+	} catch (e) {
+		return {error: e, failed: true};
+	}
+})`
+
+// txOptions reads dbTransaction settings: isolation level and read_only flag
+func txOptions(settings map[string]interface{}) (*sql.TxOptions, error) {
+	opts := &sql.TxOptions{}
+
+	if isolation, ok := stringField(settings, "isolation"); ok {
+		name := strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(isolation))
+		level, ok := isolationLevels[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown isolation level %q", isolation)
+		}
+		opts.Isolation = level
+	}
+	opts.ReadOnly = boolField(settings, "read_only")
+
+	return opts, nil
+}
+
+// txObject creates js object with exec and query functions running statements in the transaction,
+// they return the same results as dbExec and dbQuery and throw DBError on failure
+func txObject(vm *otto.Otto, tx *sql.Tx, loc *time.Location) *otto.Object {
+	object, _ := vm.Object(`({})`)
+
+	object.Set("exec", func(call otto.FunctionCall) otto.Value {
+		query, _ := call.Argument(0).ToString()
+		res, err := tx.Exec(query, dbArguments(call, 1)...)
+		if err != nil {
+			panic(call.Otto.MakeCustomError("DBError", err.Error()))
+		}
+		result, _ := otto.ToValue(execResultJSON(res))
+		return result
+	})
+
+	object.Set("query", func(call otto.FunctionCall) otto.Value {
+		query, _ := call.Argument(0).ToString()
+		rows, err := queryDB(tx, query, dbArguments(call, 1), loc)
+		if err != nil {
+			panic(call.Otto.MakeCustomError("DBError", err.Error()))
+		}
+		result, _ := otto.ToValue(rowsJSON(rows))
+		return result
+	})
+
+	return object
+}
+
+func (a *application) getDBTransactionFunc() func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		if !call.Argument(0).IsFunction() {
+			panic(call.Otto.MakeTypeError("dbTransaction expects function"))
+		}
+		if a.dbClient == nil {
+			panic(call.Otto.MakeCustomError("DBError", "Database is not configured"))
+		}
+
+		settings := map[string]interface{}{}
+		if call.Argument(1).IsObject() {
+			settings = objectArgument(call, 1)
+		}
+		opts, err := txOptions(settings)
+		if err != nil {
+			panic(call.Otto.MakeTypeError(err.Error()))
+		}
+
+		tx, err := a.dbClient.BeginTx(context.Background(), opts)
+		if err != nil {
+			panic(call.Otto.MakeCustomError("DBError", err.Error()))
+		}
+		done := false
+		//rolls back if handler is aborted
+		defer func() {
+			if !done {
+				tx.Rollback()
+			}
+		}()
+
+		wrapper, _ := call.Otto.Run(callInTransaction)
+		outcome, err := wrapper.Call(otto.NullValue(), call.Argument(0), txObject(call.Otto, tx, a.dbLocation))
+		if err != nil {
+			panic(call.Otto.MakeCustomError("DBError", err.Error()))
+		}
+
+		if failed, _ := outcome.Object().Get("failed"); failed.IsDefined() {
+			done = true
+			if err := tx.Rollback(); err != nil {
+				log.Error("Error rolling back transaction ", err)
+			}
+			thrown, _ := outcome.Object().Get("error")
+			panic(thrown)
+		}
+
+		done = true
+		if err := tx.Commit(); err != nil {
+			panic(call.Otto.MakeCustomError("DBError", err.Error()))
+		}
+
+		result, _ := outcome.Object().Get("result")
+		return result
+	}
+}
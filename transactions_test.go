@@ -0,0 +1,90 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	a := &application{dbClient: db}
+	vm := otto.New()
+	vm.Set("dbTransaction", a.getDBTransactionFunc())
+
+	//committed
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO orders").WithArgs("42").WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectQuery("SELECT id FROM orders").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("7"))
+	mock.ExpectCommit()
+
+	val, err := vm.Run(`dbTransaction(function (tx) {
+		var order = JSON.parse(tx.exec("INSERT INTO orders (user_id) VALUES ($1)", "42"));
+		var rows = JSON.parse(tx.query("SELECT id FROM orders"));
+		return order.lastInsertId + ":" + rows[0].id;
+	}, {isolation: "serializable"})`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "7:7", val.String())
+
+	//rolled back on exception, the exception is rethrown unchanged
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO orders").WillReturnResult(sqlmock.NewResult(8, 1))
+	mock.ExpectRollback()
+
+	val, err = vm.Run(`try {
+		dbTransaction(function (tx) {
+			tx.exec("INSERT INTO orders (user_id) VALUES ($1)", "42");
+			throw {code: "OUT_OF_STOCK"};
+		});
+	} catch (e) {
+		e.code
+	}`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "OUT_OF_STOCK", val.String())
+
+	//rolled back on failed statement
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO items").WillReturnError(errors.New("constraint violation"))
+	mock.ExpectRollback()
+
+	val, err = vm.Run(`try {
+		dbTransaction(function (tx) {
+			tx.exec("INSERT INTO items (order_id) VALUES ($1)", 8);
+		});
+	} catch (e) {
+		e.name + ": " + e.message
+	}`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "DBError: constraint violation", val.String())
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestTxOptions(t *testing.T) {
+	opts, err := txOptions(map[string]interface{}{"isolation": "Repeatable Read", "read_only": true})
+	assert.Nil(t, err)
+	assert.Equal(t, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, opts)
+
+	opts, err = txOptions(map[string]interface{}{"isolation": "read_committed"})
+	assert.Nil(t, err)
+	assert.Equal(t, &sql.TxOptions{Isolation: sql.LevelReadCommitted}, opts)
+
+	opts, err = txOptions(map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.Equal(t, &sql.TxOptions{}, opts)
+
+	_, err = txOptions(map[string]interface{}{"isolation": "chaos"})
+	assert.NotNil(t, err)
+}
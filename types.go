@@ -1,31 +1,53 @@
 package main
 
 import (
+	"encoding/json"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"database/sql"
 
-	"github.com/ReneKroon/ttlcache"
 	"github.com/robertkrimen/otto"
 	"github.com/yanzay/tbot/v2"
 )
 
 type application struct {
-	tgClient       Telebot
-	cache          *ttlcache.Cache
-	attachmentsDir string
-	token          string
-	vmFactory      VmFactory
-	dbClient       *sql.DB
-	vmTemplate     Vm
+	tgClient          Telebot
+	cache             SessionStore
+	attachmentsDir    string
+	token             string
+	vmFactory         VmFactory
+	dbClient          *sql.DB
+	dbLocation        *time.Location
+	vmTemplate        Vm
+	handlers          map[string]bool
+	handlersTemplate  Vm
+	scriptFiles       []string
+	scriptTimeout     time.Duration
+	scheduler         *scheduler
+	queue             MessageQueue
+	deliveredMessages sync.Map
+	timeouts          map[string]*time.Timer
+	timeoutsMu        sync.Mutex
+	captchas          map[string]*captchaSettings
+	challenges        map[string]*captchaChallenge
+	captchaMu         sync.Mutex
+	uploadActionSize  int64
+	downloadMaxSize   int64
+	mu                sync.RWMutex
 }
 
 type Vm interface {
 	Set(name string, value interface{}) error
 	Run(src interface{}) (otto.Value, error)
+	Compile(filename string, src interface{}) (*otto.Script, error)
 	Call(source string, argumentList ...interface{}) (otto.Value, error)
 	Object(source string) (*otto.Object, error)
 	Copy() Vm
+	Interrupt(f func())
 }
 
 type VmWrapper struct {
@@ -40,6 +62,10 @@ func (v VmWrapper) Run(src interface{}) (otto.Value, error) {
 	return v.vm.Run(src)
 }
 
+func (v VmWrapper) Compile(filename string, src interface{}) (*otto.Script, error) {
+	return v.vm.Compile(filename, src)
+}
+
 func (v VmWrapper) Call(source string, argumentList ...interface{}) (otto.Value, error) {
 	return v.vm.Call(source, nil, argumentList...)
 }
@@ -49,7 +75,17 @@ func (v VmWrapper) Object(source string) (*otto.Object, error) {
 }
 
 func (v VmWrapper) Copy() Vm {
-	return &VmWrapper{vm: v.vm.Copy()}
+	vm := v.vm.Copy()
+	vm.Interrupt = make(chan func(), 1)
+	return &VmWrapper{vm: vm}
+}
+
+// Interrupt makes vm run f before evaluating next statement, f is ignored if another interrupt is pending
+func (v VmWrapper) Interrupt(f func()) {
+	select {
+	case v.vm.Interrupt <- f:
+	default:
+	}
 }
 
 type VmFactory interface {
@@ -60,28 +96,62 @@ type VmFactoryImpl struct {
 }
 
 func (v VmFactoryImpl) GetVm() Vm {
-	return &VmWrapper{vm: otto.New()}
+	vm := otto.New()
+	vm.Interrupt = make(chan func(), 1)
+	return &VmWrapper{vm: vm}
 }
 
 type Telebot interface {
 	GetFileInfo(fileID string) (*tbot.File, error)
 	AnswerCallback(callbackQueryID string) error
-	EditInlineMarkup(chatID string, messageID int, markup *tbot.InlineKeyboardMarkup) (int, error)
+	EditInlineMarkup(chatID string, messageID int, option func(r url.Values)) (int, error)
 	AttachPhoto(chatID string, filename string, text string, option func(r url.Values)) (int, error)
 	AttachVideo(chatID string, filename string, text string, option func(r url.Values)) (int, error)
 	AttachAudio(chatID string, filename string, text string, option func(r url.Values)) (int, error)
 	AttachFile(chatID string, filename string, text string, option func(r url.Values)) (int, error)
+	AttachContent(chatID string, fileType string, name string, content []byte, text string, option func(r url.Values)) (int, error)
 	ForwardPhoto(chatID string, fileID string, text string, option func(r url.Values)) (int, error)
 	ForwardVideo(chatID string, fileID string, text string, option func(r url.Values)) (int, error)
 	ForwardAudio(chatID string, fileID string, text string, option func(r url.Values)) (int, error)
 	ForwardFile(chatID string, fileID string, text string, option func(r url.Values)) (int, error)
 	SendText(chatID string, text string, option func(r url.Values)) (int, error)
 	DeleteMsg(chatID string, messageID int) error
-	EditMsg(chatID string, messageID int, text string, markup *tbot.InlineKeyboardMarkup) error
+	EditMsg(chatID string, messageID int, text string, option func(r url.Values)) error
+	SendAlbum(chatID string, media string, files map[string]string) ([]int, error)
+	AttachAnimation(chatID string, filename string, text string, option func(r url.Values)) (int, error)
+	ForwardAnimation(chatID string, fileID string, text string, option func(r url.Values)) (int, error)
+	ShareLocation(chatID string, latitude float64, longitude float64, livePeriod int) (int, error)
+	EditLiveLocation(chatID string, messageID int, latitude float64, longitude float64) error
+	StopLiveLocation(chatID string, messageID int) error
+	ShareVenue(chatID string, latitude float64, longitude float64, title string, address string) (int, error)
+	ShareContact(chatID string, phoneNumber string, firstName string, lastName string) (int, error)
+	RollDice(chatID string, emoji string) (int, int, error)
+	CreatePoll(chatID string, question string, options []string, option func(r url.Values)) (int, string, error)
+	ClosePoll(chatID string, messageID int) (string, error)
+	AnswerInline(inlineQueryID string, results string, option func(r url.Values)) error
+	CreateInvoice(chatID string, payload string, providerToken string, invoice *tbot.Invoice, prices []tbot.LabeledPrice, option func(r url.Values)) (int, error)
+	AnswerPreCheckout(preCheckoutQueryID string, ok bool, errorMessage string) error
+	BanMember(chatID string, userID int, untilDate int64) error
+	UnbanMember(chatID string, userID int) error
+	RestrictMember(chatID string, userID int, permissions *tbot.ChatPermissions, untilDate int64) error
+	DefaultPermissions(chatID string) (*tbot.ChatPermissions, error)
+	PromoteMember(chatID string, userID int, option func(r url.Values)) error
+	PinMsg(chatID string, messageID int, silent bool) error
+	UnpinMsg(chatID string, messageID int) error
+	SetTitle(chatID string, title string) error
+	SetDescription(chatID string, description string) error
+	SetPhoto(chatID string, filename string) error
+	GetAdmins(chatID string) ([]*tbot.ChatMember, error)
+	CountMembers(chatID string) (int, error)
+	CreateInviteLink(chatID string, option func(r url.Values)) (string, error)
+	ApproveJoin(chatID string, userID int) error
+	DeclineJoin(chatID string, userID int) error
+	SendAction(chatID string, action string) error
 }
 
 type TbotWrapper struct {
 	*tbot.Client
+	token string
 }
 
 func (t *TbotWrapper) AnswerCallback(callbackQueryID string) error {
@@ -96,13 +166,13 @@ func (t *TbotWrapper) DeleteMsg(chatID string, messageID int) error {
 	return t.DeleteMessage(chatID, messageID)
 }
 
-func (t *TbotWrapper) EditMsg(chatID string, messageID int, text string, markup *tbot.InlineKeyboardMarkup) error {
-	_, err := t.EditMessageText(chatID, messageID, text, tbot.OptParseModeHTML, tbot.OptInlineKeyboardMarkup(markup))
+func (t *TbotWrapper) EditMsg(chatID string, messageID int, text string, option func(r url.Values)) error {
+	_, err := t.EditMessageText(chatID, messageID, text, tbot.OptParseModeHTML, option)
 	return err
 }
 
-func (t *TbotWrapper) EditInlineMarkup(chatID string, messageID int, markup *tbot.InlineKeyboardMarkup) (int, error) {
-	msg, err := t.EditMessageReplyMarkup(chatID, messageID, tbot.OptInlineKeyboardMarkup(markup))
+func (t *TbotWrapper) EditInlineMarkup(chatID string, messageID int, option func(r url.Values)) (int, error) {
+	msg, err := t.EditMessageReplyMarkup(chatID, messageID, option)
 	return msg.MessageID, err
 }
 
@@ -126,6 +196,20 @@ func (t *TbotWrapper) AttachFile(chatID string, filename string, text string, op
 	return msg.MessageID, err
 }
 
+// AttachContent uploads in-memory content as file with the name, file type is photo, video, audio or document
+func (t *TbotWrapper) AttachContent(chatID string, fileType string, name string, content []byte, text string, option func(r url.Values)) (int, error) {
+	req := url.Values{}
+	req.Set("chat_id", chatID)
+	tbot.OptCaption(text)(req)
+	tbot.OptParseModeHTML(req)
+	option(req)
+
+	msg := &tbot.Message{}
+	err := callAPIWithContent(t.token, "send"+strings.Title(fileType), req, fileType, name, content, msg)
+
+	return msg.MessageID, err
+}
+
 func (t *TbotWrapper) ForwardPhoto(chatID string, fileID string, text string, option func(r url.Values)) (int, error) {
 	msg, err := t.SendPhoto(chatID, fileID, tbot.OptCaption(text), tbot.OptParseModeHTML, option)
 	return msg.MessageID, err
@@ -150,3 +234,259 @@ func (t *TbotWrapper) SendText(chatID string, text string, option func(r url.Val
 	msg, err := t.SendMessage(chatID, text, tbot.OptParseModeHTML, option)
 	return msg.MessageID, err
 }
+
+// SendAlbum sends json encoded list of input media, files map attach:// names of uploaded media to local paths
+func (t *TbotWrapper) SendAlbum(chatID string, media string, files map[string]string) ([]int, error) {
+	req := url.Values{}
+	req.Set("chat_id", chatID)
+	req.Set("media", media)
+
+	var msgs []tbot.Message
+	err := callAPIWithFiles(t.token, "sendMediaGroup", req, files, &msgs)
+
+	ids := make([]int, len(msgs))
+	for i := range msgs {
+		ids[i] = msgs[i].MessageID
+	}
+
+	return ids, err
+}
+
+func (t *TbotWrapper) AttachAnimation(chatID string, filename string, text string, option func(r url.Values)) (int, error) {
+	msg, err := t.SendAnimationFile(chatID, filename, tbot.OptCaption(text), tbot.OptParseModeHTML, option)
+	return msg.MessageID, err
+}
+
+func (t *TbotWrapper) ForwardAnimation(chatID string, fileID string, text string, option func(r url.Values)) (int, error) {
+	msg, err := t.SendAnimation(chatID, fileID, tbot.OptCaption(text), tbot.OptParseModeHTML, option)
+	return msg.MessageID, err
+}
+
+// ShareLocation sends a point on the map, location is live and can be edited if live period is positive
+func (t *TbotWrapper) ShareLocation(chatID string, latitude float64, longitude float64, livePeriod int) (int, error) {
+	var msg *tbot.Message
+	var err error
+	if livePeriod > 0 {
+		msg, err = t.SendLocation(chatID, latitude, longitude, tbot.OptLivePeriod(livePeriod))
+	} else {
+		msg, err = t.SendLocation(chatID, latitude, longitude)
+	}
+	return msg.MessageID, err
+}
+
+func (t *TbotWrapper) EditLiveLocation(chatID string, messageID int, latitude float64, longitude float64) error {
+	_, err := t.EditMessageLiveLocation(chatID, messageID, latitude, longitude)
+	return err
+}
+
+func (t *TbotWrapper) StopLiveLocation(chatID string, messageID int) error {
+	_, err := t.StopMessageLiveLocation(chatID, messageID)
+	return err
+}
+
+func (t *TbotWrapper) ShareVenue(chatID string, latitude float64, longitude float64, title string, address string) (int, error) {
+	msg, err := t.SendVenue(chatID, latitude, longitude, title, address)
+	return msg.MessageID, err
+}
+
+func (t *TbotWrapper) ShareContact(chatID string, phoneNumber string, firstName string, lastName string) (int, error) {
+	msg, err := t.SendContact(chatID, phoneNumber, firstName, tbot.OptLastName(lastName))
+	return msg.MessageID, err
+}
+
+// RollDice sends an animated emoji with random value and returns message id and the value, tbot lacks sendDice
+func (t *TbotWrapper) RollDice(chatID string, emoji string) (int, int, error) {
+	req := url.Values{}
+	req.Set("chat_id", chatID)
+	if emoji != "" {
+		req.Set("emoji", emoji)
+	}
+
+	msg := &struct {
+		MessageID int `json:"message_id"`
+		Dice      struct {
+			Value int `json:"value"`
+		} `json:"dice"`
+	}{}
+	err := callAPI(t.token, "sendDice", req, msg)
+
+	return msg.MessageID, msg.Dice.Value, err
+}
+
+// CreatePoll sends a poll and returns message id and poll id
+func (t *TbotWrapper) CreatePoll(chatID string, question string, options []string, option func(r url.Values)) (int, string, error) {
+	msg, err := t.SendPoll(chatID, question, options, option)
+	if err != nil {
+		return 0, "", err
+	}
+
+	pollID := ""
+	if msg.Poll != nil {
+		pollID = msg.Poll.ID
+	}
+
+	return msg.MessageID, pollID, nil
+}
+
+// ClosePoll stops a poll and returns its final results as json, tbot poll lacks type, anonymity and quiz fields
+func (t *TbotWrapper) ClosePoll(chatID string, messageID int) (string, error) {
+	req := url.Values{}
+	req.Set("chat_id", chatID)
+	req.Set("message_id", strconv.Itoa(messageID))
+
+	var poll json.RawMessage
+	err := callAPI(t.token, "stopPoll", req, &poll)
+
+	return string(poll), err
+}
+
+// AnswerInline answers inline query with results encoded to json, tbot accepts only its own result types
+func (t *TbotWrapper) AnswerInline(inlineQueryID string, results string, option func(r url.Values)) error {
+	req := url.Values{}
+	req.Set("inline_query_id", inlineQueryID)
+	req.Set("results", results)
+	option(req)
+
+	return callAPI(t.token, "answerInlineQuery", req, nil)
+}
+
+func (t *TbotWrapper) CreateInvoice(chatID string, payload string, providerToken string, invoice *tbot.Invoice, prices []tbot.LabeledPrice, option func(r url.Values)) (int, error) {
+	msg, err := t.SendInvoice(chatID, payload, providerToken, invoice, prices, option)
+	if err != nil {
+		return 0, err
+	}
+	return msg.MessageID, nil
+}
+
+func (t *TbotWrapper) AnswerPreCheckout(preCheckoutQueryID string, ok bool, errorMessage string) error {
+	if ok {
+		return t.AnswerPreCheckoutQuery(preCheckoutQueryID, true)
+	}
+	return t.AnswerPreCheckoutQuery(preCheckoutQueryID, false, tbot.OptErrorMessage(errorMessage))
+}
+
+func optUntilDate(untilDate int64) func(r url.Values) {
+	return func(r url.Values) {
+		if untilDate > 0 {
+			r.Set("until_date", strconv.FormatInt(untilDate, 10))
+		}
+	}
+}
+
+func (t *TbotWrapper) BanMember(chatID string, userID int, untilDate int64) error {
+	return t.KickChatMember(chatID, userID, optUntilDate(untilDate))
+}
+
+// UnbanMember unbans user, a member which is not banned is kept in the chat
+func (t *TbotWrapper) UnbanMember(chatID string, userID int) error {
+	req := url.Values{}
+	req.Set("chat_id", chatID)
+	req.Set("user_id", strconv.Itoa(userID))
+	req.Set("only_if_banned", "true")
+
+	return callAPI(t.token, "unbanChatMember", req, nil)
+}
+
+func (t *TbotWrapper) RestrictMember(chatID string, userID int, permissions *tbot.ChatPermissions, untilDate int64) error {
+	return t.RestrictChatMember(chatID, userID, permissions, optUntilDate(untilDate))
+}
+
+// DefaultPermissions returns default permissions of chat members
+func (t *TbotWrapper) DefaultPermissions(chatID string) (*tbot.ChatPermissions, error) {
+	chat, err := t.GetChat(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.Permissions == nil {
+		return &tbot.ChatPermissions{}, nil
+	}
+
+	return chat.Permissions, nil
+}
+
+// PromoteMember sets administrator rights of user, tbot misspells can_promote_members and lacks newer rights
+func (t *TbotWrapper) PromoteMember(chatID string, userID int, option func(r url.Values)) error {
+	req := url.Values{}
+	req.Set("chat_id", chatID)
+	req.Set("user_id", strconv.Itoa(userID))
+	option(req)
+
+	return callAPI(t.token, "promoteChatMember", req, nil)
+}
+
+func (t *TbotWrapper) PinMsg(chatID string, messageID int, silent bool) error {
+	if silent {
+		return t.PinChatMessage(chatID, messageID, tbot.OptDisableNotification)
+	}
+	return t.PinChatMessage(chatID, messageID)
+}
+
+// UnpinMsg unpins message or the most recent pinned message if message id is zero, tbot supports only the latter
+func (t *TbotWrapper) UnpinMsg(chatID string, messageID int) error {
+	req := url.Values{}
+	req.Set("chat_id", chatID)
+	if messageID != 0 {
+		req.Set("message_id", strconv.Itoa(messageID))
+	}
+
+	return callAPI(t.token, "unpinChatMessage", req, nil)
+}
+
+func (t *TbotWrapper) SetTitle(chatID string, title string) error {
+	return t.SetChatTitle(chatID, title)
+}
+
+func (t *TbotWrapper) SetDescription(chatID string, description string) error {
+	return t.SetChatDescription(chatID, description)
+}
+
+func (t *TbotWrapper) SetPhoto(chatID string, filename string) error {
+	return t.SetChatPhoto(chatID, filename)
+}
+
+func (t *TbotWrapper) GetAdmins(chatID string) ([]*tbot.ChatMember, error) {
+	return t.GetChatAdministrators(chatID)
+}
+
+func (t *TbotWrapper) CountMembers(chatID string) (int, error) {
+	return t.GetChatMembersCount(chatID)
+}
+
+// CreateInviteLink creates additional invite link of chat, tbot only supports replacing the primary one
+func (t *TbotWrapper) CreateInviteLink(chatID string, option func(r url.Values)) (string, error) {
+	req := url.Values{}
+	req.Set("chat_id", chatID)
+	option(req)
+
+	link := &struct {
+		InviteLink string `json:"invite_link"`
+	}{}
+	err := callAPI(t.token, "createChatInviteLink", req, link)
+
+	return link.InviteLink, err
+}
+
+func (t *TbotWrapper) ApproveJoin(chatID string, userID int) error {
+	req := url.Values{}
+	req.Set("chat_id", chatID)
+	req.Set("user_id", strconv.Itoa(userID))
+
+	return callAPI(t.token, "approveChatJoinRequest", req, nil)
+}
+
+func (t *TbotWrapper) DeclineJoin(chatID string, userID int) error {
+	req := url.Values{}
+	req.Set("chat_id", chatID)
+	req.Set("user_id", strconv.Itoa(userID))
+
+	return callAPI(t.token, "declineChatJoinRequest", req, nil)
+}
+
+// SendAction shows chat action, tbot does not allow actions it does not define
+func (t *TbotWrapper) SendAction(chatID string, action string) error {
+	req := url.Values{}
+	req.Set("chat_id", chatID)
+	req.Set("action", action)
+
+	return callAPI(t.token, "sendChatAction", req, nil)
+}
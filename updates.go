@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/yanzay/tbot/v2"
+)
+
+// long polling timeout in seconds, must be less than timeout of api client
+const pollingTimeout = 25
+
+// update types bot receives, some of them are not delivered unless requested explicitly
+var allowedUpdates = []string{"message", "callback_query", "poll", "poll_answer", "inline_query", "chosen_inline_result", "pre_checkout_query",
+	"chat_member", "my_chat_member", "chat_join_request"}
+
+// Update extends tbot update with update types and fields tbot does not support
+type Update struct {
+	tbot.Update
+	Poll            *Poll              `json:"poll"`
+	PollAnswer      *PollAnswer        `json:"poll_answer"`
+	ChatMember      *ChatMemberUpdated `json:"chat_member"`
+	MyChatMember    *ChatMemberUpdated `json:"my_chat_member"`
+	ChatJoinRequest *ChatJoinRequest   `json:"chat_join_request"`
+}
+
+// Poll is a complete poll, tbot lacks type, anonymity and quiz fields
+type Poll struct {
+	ID                    string            `json:"id"`
+	Question              string            `json:"question"`
+	Options               []tbot.PollOption `json:"options"`
+	TotalVoterCount       int               `json:"total_voter_count"`
+	IsClosed              bool              `json:"is_closed"`
+	IsAnonymous           bool              `json:"is_anonymous"`
+	Type                  string            `json:"type"`
+	AllowsMultipleAnswers bool              `json:"allows_multiple_answers"`
+	CorrectOptionID       *int              `json:"correct_option_id"`
+	Explanation           string            `json:"explanation"`
+}
+
+// PollAnswer is an answer of a user in a non-anonymous poll
+type PollAnswer struct {
+	PollID    string     `json:"poll_id"`
+	User      *tbot.User `json:"user"`
+	OptionIDs []int      `json:"option_ids"`
+}
+
+func allowedUpdatesParam() string {
+	data, _ := json.Marshal(allowedUpdates)
+	return string(data)
+}
+
+// getUpdates fetches updates starting from offset, waiting for them up to timeout seconds
+func getUpdates(token string, offset int, timeout int) ([]*Update, error) {
+	req := url.Values{}
+	req.Set("offset", strconv.Itoa(offset))
+	req.Set("timeout", strconv.Itoa(timeout))
+	req.Set("allowed_updates", allowedUpdatesParam())
+
+	var updates []*Update
+	err := callAPI(token, "getUpdates", req, &updates)
+
+	return updates, err
+}
+
+// pollUpdates receives updates with long polling and dispatches each of them in a separate goroutine
+func pollUpdates(token string, dispatch func(*Update)) error {
+	//updates can not be polled while webhook is set
+	if err := callAPI(token, "deleteWebhook", url.Values{}, nil); err != nil {
+		return err
+	}
+
+	offset := 0
+	for {
+		updates, err := getUpdates(token, offset, pollingTimeout)
+		if err != nil {
+			log.Error("Error getting updates ", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			go dispatch(update)
+		}
+	}
+}
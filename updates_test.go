@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUpdates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bottoken/getUpdates", r.URL.Path)
+		r.ParseForm()
+		assert.Equal(t, "10", r.PostForm.Get("offset"))
+		assert.Equal(t, allowedUpdatesParam(), r.PostForm.Get("allowed_updates"))
+
+		rw.Write([]byte(`{"ok":true,"result":[
+			{"update_id":10,"message":{"message_id":1,"chat":{"id":42},"text":"hi"}},
+			{"update_id":11,"poll_answer":{"poll_id":"poll1","user":{"id":42},"option_ids":[1]}}
+		]}`))
+	}))
+	defer server.Close()
+
+	defaultURL := apiBaseURL
+	apiBaseURL = server.URL
+	defer func() { apiBaseURL = defaultURL }()
+
+	updates, err := getUpdates("token", 10, 0)
+
+	assert.Nil(t, err)
+	assert.Len(t, updates, 2)
+	assert.Equal(t, "hi", updates[0].Message.Text)
+	assert.Equal(t, "poll1", updates[1].PollAnswer.PollID)
+	assert.Equal(t, []int{1}, updates[1].PollAnswer.OptionIDs)
+}
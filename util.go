@@ -2,12 +2,15 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -33,6 +36,30 @@ func GetEnvAsInt(name string, defaultVal int) int {
 	return defaultVal
 }
 
+// ParseSize converts positive size in bytes, optionally followed by KB, MB or GB, to number of bytes
+func ParseSize(size string) (int64, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(size, unit.suffix) {
+			size = strings.TrimSpace(strings.TrimSuffix(size, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	value, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("Invalid size %s", size)
+	}
+
+	return value * multiplier, nil
+}
+
 func FileExists(name string) bool {
 	_, err := os.Stat(name)
 
@@ -68,6 +95,11 @@ func GetFileType(path string) FileType {
 		return OTHER
 	}
 
+	return DetectFileType(head)
+}
+
+// DetectFileType determines file type by file header
+func DetectFileType(head []byte) FileType {
 	if filetype.IsImage(head) {
 		return PHOTO
 	} else if filetype.IsVideo(head) {
@@ -183,3 +215,31 @@ func ReadFile(path string) (string, error) {
 
 	return string(fileContent), nil
 }
+
+// WriteFileAtomic writes data to a temporary file and renames it, so that readers never see partially written file
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+"*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// RandomID returns random hex string suitable for identifiers
+func RandomID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
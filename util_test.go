@@ -77,6 +77,20 @@ func TestParseFileType(t *testing.T) {
 	assert.Equal(t, OTHER, ParseFileType("docx"))
 }
 
+func TestParseSize(t *testing.T) {
+	for size, expected := range map[string]int64{"512": 512, "10b": 10, "512KB": 512 << 10, "1MB": 1 << 20, " 2 gb ": 2 << 30} {
+		actual, err := ParseSize(size)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	for _, size := range []string{"1TB", "0", "-1KB"} {
+		_, err := ParseSize(size)
+		assert.NotNil(t, err, size)
+	}
+}
+
 func TestIsValidUrl(t *testing.T) {
 	assert.False(t, isValidUrl(""))
 
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/gommon/log"
+)
+
+// header telegram uses to pass secret token to webhook
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+type webhookConfig struct {
+	url      string
+	listen   string
+	secret   string
+	certFile string
+	keyFile  string
+}
+
+func getWebhookConfig() webhookConfig {
+	return webhookConfig{
+		url:      GetEnv("WEBHOOK_URL", ""),
+		listen:   GetEnv("WEBHOOK_LISTEN", ":8443"),
+		secret:   GetEnv("WEBHOOK_SECRET", ""),
+		certFile: GetEnv("WEBHOOK_CERT", ""),
+		keyFile:  GetEnv("WEBHOOK_KEY", ""),
+	}
+}
+
+func setWebhook(token string, webhookURL string, secret string) error {
+	req := url.Values{}
+	req.Set("url", webhookURL)
+	if secret != "" {
+		req.Set("secret_token", secret)
+	}
+	req.Set("allowed_updates", allowedUpdatesParam())
+
+	return callAPI(token, "setWebhook", req, nil)
+}
+
+func webhookHandler(secret string, dispatch func(*Update)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if secret != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(secretTokenHeader)), []byte(secret)) != 1 {
+			log.Warn("Rejected webhook request with invalid secret token from ", r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		update := &Update{}
+		if err := json.NewDecoder(r.Body).Decode(update); err != nil {
+			log.Error("Error decoding webhook update ", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		go dispatch(update)
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// serveWebhook registers webhook and serves updates until server fails
+func serveWebhook(token string, config webhookConfig, dispatch func(*Update)) error {
+	if err := setWebhook(token, config.url, config.secret); err != nil {
+		return err
+	}
+
+	path := "/"
+	if u, err := url.Parse(config.url); err == nil && u.Path != "" {
+		path = u.Path
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, webhookHandler(config.secret, dispatch))
+
+	log.Info("Listening for webhook updates on ", config.listen, path)
+
+	if config.certFile != "" && config.keyFile != "" {
+		return http.ListenAndServeTLS(config.listen, config.certFile, config.keyFile, mux)
+	}
+
+	return http.ListenAndServe(config.listen, mux)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	updates := make(chan *Update, 1)
+	handler := webhookHandler("secret", func(u *Update) {
+		updates <- u
+	})
+
+	//valid update
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"update_id":1,"message":{"message_id":2,"text":"hi","chat":{"id":3}}}`))
+	req.Header.Set(secretTokenHeader, "secret")
+	rw := httptest.NewRecorder()
+
+	handler(rw, req)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	update := <-updates
+	assert.Equal(t, "hi", update.Message.Text)
+	assert.Equal(t, "3", update.Message.Chat.ID)
+
+	//invalid secret
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"update_id":1}`))
+	req.Header.Set(secretTokenHeader, "wrong")
+	rw = httptest.NewRecorder()
+
+	handler(rw, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+
+	//malformed body
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{`))
+	req.Header.Set(secretTokenHeader, "secret")
+	rw = httptest.NewRecorder()
+
+	handler(rw, req)
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	//wrong method
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rw = httptest.NewRecorder()
+
+	handler(rw, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+}
+
+func TestSetWebhook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		assert.Equal(t, "/bottoken/setWebhook", r.URL.Path)
+		assert.Equal(t, "https://bot.example.com/hook", r.PostForm.Get("url"))
+		assert.Equal(t, "secret", r.PostForm.Get("secret_token"))
+		rw.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer server.Close()
+
+	defaultURL := apiBaseURL
+	apiBaseURL = server.URL
+	defer func() { apiBaseURL = defaultURL }()
+
+	assert.Nil(t, setWebhook("token", "https://bot.example.com/hook", "secret"))
+}